@@ -0,0 +1,297 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charon"
+	"github.com/charon/errors"
+)
+
+const (
+	// pbkdf2Prefix the prefix of the password hashes of the credentials file
+	pbkdf2Prefix = "$pbkdf2-sha256$"
+
+	// defaultIterations the PBKDF2 iterations of the hashes made by HashPassword
+	defaultIterations = 600000
+
+	// the default lockout after repeated failures
+	defaultMaxFailures = 5
+	defaultLockout     = 15 * time.Minute
+)
+
+// BasicOptions configures the Basic authenticator, see NewBasicAuthenticator
+type BasicOptions struct {
+	// File the path of the credentials file, reloaded when it changes
+	File string
+
+	// Realm the realm of the WWW-Authenticate challenge sent with the failures
+	Realm string
+
+	// MaxFailures the consecutive failures after which a user is locked out, defaults to 5
+	MaxFailures int
+
+	// Lockout the duration of the lockout, defaults to 15 minutes
+	Lockout time.Duration
+}
+
+// credential the password hash and roles of a user
+type credential struct {
+	iterations int
+	salt       []byte
+	hash       []byte
+	roles      []string
+}
+
+// failures the consecutive authentication failures of a user
+type failures struct {
+	count       int
+	lockedUntil time.Time
+}
+
+// basicAuthenticator verifies the Basic credentials of the requests
+type basicAuthenticator struct {
+	options BasicOptions
+	file    *watchedFile
+
+	mu          sync.RWMutex
+	credentials map[string]credential
+
+	failuresMu sync.Mutex
+	failures   map[string]*failures
+}
+
+// dummyCredential the credential checked for unknown users, so that they take as long to fail as the known ones
+var dummyCredential = credential{iterations: defaultIterations, salt: make([]byte, 16), hash: make([]byte, sha256.Size)}
+
+// NewBasicAuthenticator returns the authentication verifying the Basic credentials of the "Authorization"
+// header of the requests against a credentials file. Each line of the file holds a user as
+//
+//	username:$pbkdf2-sha256$iterations$salt$hash[:role,role]
+//
+// where salt and hash are base64 encoded without padding, see HashPassword. Blank lines and lines
+// starting with "#" are skipped. The principal of the request, see charon.PrincipalFromContext, holds
+// the username as its subject and the roles of the user. Users failing to authenticate MaxFailures
+// times in a row are locked out for the Lockout duration, even with the right password.
+// Returns an error if the file cannot be loaded
+func NewBasicAuthenticator(basicOptions BasicOptions) (charon.AuthenticateFunc, error) {
+	if basicOptions.MaxFailures <= 0 {
+		basicOptions.MaxFailures = defaultMaxFailures
+	}
+	if basicOptions.Lockout <= 0 {
+		basicOptions.Lockout = defaultLockout
+	}
+
+	authenticator := &basicAuthenticator{options: basicOptions, failures: make(map[string]*failures)}
+	authenticator.file = &watchedFile{path: basicOptions.File, load: authenticator.load}
+	if err := authenticator.file.reload(); err != nil {
+		return nil, err
+	}
+	return authenticator.authenticate, nil
+}
+
+// HashPassword returns the PBKDF2-SHA256 hash of the password with a random salt, in the format
+// of the credentials file of the Basic authenticator
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	hash := pbkdf2(sha256.New, []byte(password), salt, defaultIterations, sha256.Size)
+	return fmt.Sprint(pbkdf2Prefix, defaultIterations, "$", base64.RawStdEncoding.EncodeToString(salt), "$",
+		base64.RawStdEncoding.EncodeToString(hash)), nil
+}
+
+// authenticate verifies the Basic credentials of the request
+func (authenticator *basicAuthenticator) authenticate(ctx context.Context, header http.Header) (context.Context, *url.Userinfo, errors.Error) {
+	username, password, ok := parseBasic(header.Get("Authorization"))
+	if !ok {
+		return nil, nil, authenticator.failure("Missing basic credentials")
+	}
+
+	if authenticator.isLocked(username) {
+		return nil, nil, authenticator.failure(fmt.Sprint("User ", username, " is locked out"))
+	}
+
+	authenticator.file.refresh()
+	authenticator.mu.RLock()
+	cred, known := authenticator.credentials[username]
+	authenticator.mu.RUnlock()
+	if !known {
+		dummyCredential.verify(password)
+		return nil, nil, authenticator.failure(fmt.Sprint("Unknown user ", username))
+	}
+	if !cred.verify(password) {
+		authenticator.recordFailure(username)
+		return nil, nil, authenticator.failure(fmt.Sprint("Invalid password for user ", username))
+	}
+
+	authenticator.failuresMu.Lock()
+	delete(authenticator.failures, username)
+	authenticator.failuresMu.Unlock()
+
+	principal := &charon.Principal{Subject: username, Roles: cred.roles}
+	return charon.WithPrincipal(ctx, principal), url.User(username), nil
+}
+
+// failure returns the AuthenticationError challenging the client for Basic credentials
+func (authenticator *basicAuthenticator) failure(reason string) errors.Error {
+	return errors.AuthenticationError{
+		Err:       reason,
+		Mess:      "Unauthorized",
+		Challenge: challenge("Basic", authenticator.options.Realm, `charset="UTF-8"`),
+	}
+}
+
+// isLocked checks if the user is locked out, the failures are forgotten once the lockout is over
+func (authenticator *basicAuthenticator) isLocked(username string) bool {
+	authenticator.failuresMu.Lock()
+	defer authenticator.failuresMu.Unlock()
+	userFailures, ok := authenticator.failures[username]
+	if !ok || userFailures.lockedUntil.IsZero() {
+		return false
+	}
+	if time.Now().Before(userFailures.lockedUntil) {
+		return true
+	}
+	delete(authenticator.failures, username)
+	return false
+}
+
+// recordFailure counts a failure of the user, locking it out after MaxFailures in a row
+func (authenticator *basicAuthenticator) recordFailure(username string) {
+	authenticator.failuresMu.Lock()
+	defer authenticator.failuresMu.Unlock()
+	userFailures, ok := authenticator.failures[username]
+	if !ok {
+		userFailures = &failures{}
+		authenticator.failures[username] = userFailures
+	}
+	userFailures.count++
+	if userFailures.count >= authenticator.options.MaxFailures {
+		userFailures.lockedUntil = time.Now().Add(authenticator.options.Lockout)
+	}
+}
+
+// load parses the credentials file
+func (authenticator *basicAuthenticator) load(data []byte) error {
+	credentials := make(map[string]credential)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, ":")
+		if len(fields) < 2 || len(fields) > 3 || fields[0] == "" {
+			return fmt.Errorf("line %d: expected username:hash[:roles]", lineNo)
+		}
+		cred, err := parseHash(fields[1])
+		if err != nil {
+			return fmt.Errorf("line %d: %s", lineNo, err.Error())
+		}
+		if len(fields) == 3 {
+			for _, role := range strings.Split(fields[2], ",") {
+				if role = strings.TrimSpace(role); role != "" {
+					cred.roles = append(cred.roles, role)
+				}
+			}
+		}
+		credentials[fields[0]] = cred
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	authenticator.mu.Lock()
+	defer authenticator.mu.Unlock()
+	authenticator.credentials = credentials
+	return nil
+}
+
+// parseHash parses the "$pbkdf2-sha256$iterations$salt$hash" hash
+func parseHash(encoded string) (credential, error) {
+	parts := strings.Split(strings.TrimPrefix(encoded, pbkdf2Prefix), "$")
+	if !strings.HasPrefix(encoded, pbkdf2Prefix) || len(parts) != 3 {
+		return credential{}, fmt.Errorf("unsupported hash, expected %siterations$salt$hash", pbkdf2Prefix)
+	}
+	iterations, err := strconv.Atoi(parts[0])
+	if err != nil || iterations <= 0 {
+		return credential{}, fmt.Errorf("invalid iterations %q", parts[0])
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return credential{}, fmt.Errorf("invalid salt")
+	}
+	hash, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil || len(hash) == 0 {
+		return credential{}, fmt.Errorf("invalid hash")
+	}
+	return credential{iterations: iterations, salt: salt, hash: hash}, nil
+}
+
+// verify checks the password against the hash in constant time
+func (cred credential) verify(password string) bool {
+	if len(cred.hash) == 0 {
+		return false
+	}
+	derived := pbkdf2(sha256.New, []byte(password), cred.salt, cred.iterations, len(cred.hash))
+	return subtle.ConstantTimeCompare(derived, cred.hash) == 1
+}
+
+// parseBasic parses the username and password of the Basic Authorization header
+func parseBasic(authorization string) (string, string, bool) {
+	if len(authorization) < 6 || !strings.EqualFold(authorization[:6], "Basic ") {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(authorization[6:]))
+	if err != nil {
+		return "", "", false
+	}
+	username, password, ok := strings.Cut(string(decoded), ":")
+	if !ok || username == "" {
+		return "", "", false
+	}
+	return username, password, true
+}
+
+// pbkdf2 derives a key of keyLen bytes from the password and salt, as per RFC 8018
+func pbkdf2(newHash func() hash.Hash, password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(newHash, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+
+	derived := make([]byte, 0, blocks*hashLen)
+	u := make([]byte, hashLen)
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write([]byte{byte(block >> 24), byte(block >> 16), byte(block >> 8), byte(block)})
+		u = prf.Sum(u[:0])
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		derived = append(derived, t...)
+	}
+	return derived[:keyLen]
+}
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/charon"
+	"github.com/charon/errors"
+	"github.com/charon/utils"
+)
+
+// testHash returns the credentials file hash of the password, with few iterations to keep the tests fast
+func testHash(password string) string {
+	salt := []byte("0123456789abcdef")
+	hash := pbkdf2(sha256.New, []byte(password), salt, 1000, sha256.Size)
+	return fmt.Sprint(pbkdf2Prefix, 1000, "$", base64.RawStdEncoding.EncodeToString(salt), "$",
+		base64.RawStdEncoding.EncodeToString(hash))
+}
+
+// writeCredentials writes the credentials file and returns its path
+func writeCredentials(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "credentials")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// basicAuth returns the request header holding the Basic credentials
+func basicAuth(username, password string) http.Header {
+	header := http.Header{}
+	header.Set("Authorization", utils.GetBasicAuthHeader(username, password))
+	return header
+}
+
+func TestPBKDF2(t *testing.T) {
+	// the PBKDF2-HMAC-SHA256 vectors of RFC 7914 section 11
+	tests := []struct {
+		password   string
+		salt       string
+		iterations int
+		keyLen     int
+		expected   string
+	}{
+		{"passwd", "salt", 1, 64, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+			"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000, 64, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56" +
+			"a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	}
+	for _, test := range tests {
+		derived := pbkdf2(sha256.New, []byte(test.password), []byte(test.salt), test.iterations, test.keyLen)
+		if got := hex.EncodeToString(derived); got != test.expected {
+			t.Errorf("%s/%s/%d: expected %s, got %s", test.password, test.salt, test.iterations, test.expected, got)
+		}
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	encoded, err := HashPassword("secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cred, err := parseHash(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cred.iterations != defaultIterations || len(cred.salt) != 16 || !cred.verify("secret") {
+		t.Errorf("unexpected hash %s", encoded)
+	}
+	if other, _ := HashPassword("secret"); other == encoded {
+		t.Error("expected a random salt")
+	}
+}
+
+func TestParseHash(t *testing.T) {
+	tests := []struct {
+		encoded string
+		valid   bool
+	}{
+		{testHash("secret"), true},
+		{"$pbkdf2-sha256$1000$c2FsdA$aGFzaA", true},
+		{"$pbkdf2-sha512$1000$c2FsdA$aGFzaA", false},
+		{"$pbkdf2-sha256$1000$c2FsdA", false},
+		{"$pbkdf2-sha256$0$c2FsdA$aGFzaA", false},
+		{"$pbkdf2-sha256$many$c2FsdA$aGFzaA", false},
+		{"$pbkdf2-sha256$1000$!!$aGFzaA", false},
+		{"$pbkdf2-sha256$1000$c2FsdA$", false},
+		{"plaintext", false},
+	}
+	for _, test := range tests {
+		if _, err := parseHash(test.encoded); (err == nil) != test.valid {
+			t.Errorf("%q: expected valid %t, got %v", test.encoded, test.valid, err)
+		}
+	}
+}
+
+func TestBasicCredentialsFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		valid   bool
+	}{
+		{"users", "# users\n\nalice:" + testHash("a") + ":admin, ops\nbob:" + testHash("b") + "\n", true},
+		{"empty", "", true},
+		{"missing hash", "alice\n", false},
+		{"missing username", ":" + testHash("a") + "\n", false},
+		{"too many fields", "alice:" + testHash("a") + ":admin:extra\n", false},
+		{"invalid hash", "alice:plaintext\n", false},
+	}
+	for _, test := range tests {
+		_, err := NewBasicAuthenticator(BasicOptions{File: writeCredentials(t, test.content)})
+		if (err == nil) != test.valid {
+			t.Errorf("%s: expected valid %t, got %v", test.name, test.valid, err)
+		}
+	}
+	if _, err := NewBasicAuthenticator(BasicOptions{File: filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestBasicAuthenticate(t *testing.T) {
+	path := writeCredentials(t, "alice:"+testHash("a")+":admin, ops\n")
+	authenticate, err := NewBasicAuthenticator(BasicOptions{File: path, Realm: "api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, userInfo, authErr := authenticate(context.Background(), basicAuth("alice", "a"))
+	if authErr != nil {
+		t.Fatal(authErr.Error())
+	}
+	principal := charon.PrincipalFromContext(ctx)
+	if principal == nil || principal.Subject != "alice" || !principal.HasRole("admin") || !principal.HasRole("ops") || userInfo.Username() != "alice" {
+		t.Fatalf("unexpected principal %+v", principal)
+	}
+
+	tests := []struct {
+		name   string
+		header http.Header
+	}{
+		{"wrong password", basicAuth("alice", "b")},
+		{"unknown user", basicAuth("bob", "a")},
+		{"missing credentials", http.Header{}},
+		{"bearer token", http.Header{"Authorization": []string{"Bearer abc"}}},
+		{"invalid base64", http.Header{"Authorization": []string{"Basic !!"}}},
+		{"missing password separator", http.Header{"Authorization": []string{"Basic " + base64.StdEncoding.EncodeToString([]byte("alice"))}}},
+	}
+	for _, test := range tests {
+		_, _, authErr := authenticate(context.Background(), test.header)
+		authenticationErr, ok := authErr.(errors.AuthenticationError)
+		if !ok || authenticationErr.StatusCode() != http.StatusUnauthorized || authenticationErr.Challenge != `Basic realm="api", charset="UTF-8"` {
+			t.Errorf("%s: expected a Basic challenge, got %#v", test.name, authErr)
+		}
+	}
+}
+
+func TestBasicLockout(t *testing.T) {
+	authenticator := &basicAuthenticator{options: BasicOptions{MaxFailures: 2, Lockout: time.Hour}, failures: make(map[string]*failures)}
+	authenticator.file = &watchedFile{path: writeCredentials(t, "alice:"+testHash("a")+"\n"), load: authenticator.load}
+	if err := authenticator.file.reload(); err != nil {
+		t.Fatal(err)
+	}
+	try := func(password string) bool {
+		_, _, authErr := authenticator.authenticate(context.Background(), basicAuth("alice", password))
+		return authErr == nil
+	}
+
+	if try("wrong") || !try("a") || try("wrong") || !try("a") {
+		t.Fatal("expected the failures to be reset by a success")
+	}
+	if try("wrong") || try("wrong") {
+		t.Fatal("expected the wrong password to fail")
+	}
+	if try("a") {
+		t.Fatal("expected the user to be locked out")
+	}
+
+	authenticator.failuresMu.Lock()
+	authenticator.failures["alice"].lockedUntil = time.Now().Add(-time.Second)
+	authenticator.failuresMu.Unlock()
+	if !try("a") {
+		t.Fatal("expected the user to authenticate once the lockout is over")
+	}
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// fileCheckInterval the minimum interval between two checks of a watched file for changes
+const fileCheckInterval = time.Second
+
+// watchedFile a local file loaded again when it changes, as seen from its modification time and size
+type watchedFile struct {
+	path string
+	load func(data []byte) error
+
+	mu        sync.Mutex
+	modTime   time.Time
+	size      int64
+	lastCheck time.Time
+}
+
+// reload reads and loads the file
+func (file *watchedFile) reload() error {
+	info, err := os.Stat(file.path)
+	if err != nil {
+		return fmt.Errorf("charon/auth: unable to read %s: %s", file.path, err.Error())
+	}
+	data, err := os.ReadFile(file.path)
+	if err != nil {
+		return fmt.Errorf("charon/auth: unable to read %s: %s", file.path, err.Error())
+	}
+	if err := file.load(data); err != nil {
+		return fmt.Errorf("charon/auth: invalid file %s: %s", file.path, err.Error())
+	}
+
+	file.mu.Lock()
+	defer file.mu.Unlock()
+	file.modTime, file.size, file.lastCheck = info.ModTime(), info.Size(), time.Now()
+	return nil
+}
+
+// refresh reloads the file if it changed since it was loaded, what was loaded last is kept if it cannot be loaded
+func (file *watchedFile) refresh() {
+	file.mu.Lock()
+	if time.Since(file.lastCheck) < fileCheckInterval {
+		file.mu.Unlock()
+		return
+	}
+	file.lastCheck = time.Now()
+	modTime, size := file.modTime, file.size
+	file.mu.Unlock()
+
+	if info, err := os.Stat(file.path); err == nil && (!info.ModTime().Equal(modTime) || info.Size() != size) {
+		_ = file.reload()
+	}
+}
@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sync"
+)
+
+// jwk a single key of a JSON Web Key Set
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+
+	// RSA keys
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC keys
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+
+	// symmetric keys
+	K string `json:"k"`
+}
+
+// jwksKey a key of the set, parsed
+type jwksKey struct {
+	kid string
+	alg string
+	key interface{}
+}
+
+// jwksFile the keys of a local JWKS file, reloaded when the file changes
+type jwksFile struct {
+	file *watchedFile
+
+	mu   sync.RWMutex
+	keys []jwksKey
+}
+
+// newJWKSFile loads the keys of the JWKS file
+func newJWKSFile(path string) (*jwksFile, error) {
+	jwks := &jwksFile{}
+	jwks.file = &watchedFile{path: path, load: jwks.load}
+	if err := jwks.file.reload(); err != nil {
+		return nil, err
+	}
+	return jwks, nil
+}
+
+// load parses the keys of the set
+func (jwks *jwksFile) load(data []byte) error {
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+	jwks.mu.Lock()
+	defer jwks.mu.Unlock()
+	jwks.keys = keys
+	return nil
+}
+
+// key returns the key with the given id for the algorithm, the only key for the algorithm if the token has no key id
+func (jwks *jwksFile) key(kid, alg string) (interface{}, error) {
+	jwks.file.refresh()
+
+	jwks.mu.RLock()
+	defer jwks.mu.RUnlock()
+	var found interface{}
+	matches := 0
+	for _, key := range jwks.keys {
+		if (kid != "" && key.kid != kid) || (key.alg != "" && key.alg != alg) || !keyMatchesAlg(key.key, alg) {
+			continue
+		}
+		found = key.key
+		matches++
+	}
+	switch {
+	case matches == 1:
+		return found, nil
+	case matches > 1:
+		return nil, fmt.Errorf("ambiguous key for algorithm %q", alg)
+	case kid != "":
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	return nil, fmt.Errorf("no key for algorithm %q", alg)
+}
+
+// keyMatchesAlg checks if the key can verify the signatures of the algorithm
+func keyMatchesAlg(key interface{}, alg string) bool {
+	switch key.(type) {
+	case []byte:
+		return alg == "HS256"
+	case *rsa.PublicKey:
+		return alg == "RS256"
+	case *ecdsa.PublicKey:
+		return alg == "ES256"
+	}
+	return false
+}
+
+// parseJWKS parses the keys of the set, the keys not used for signatures or of unsupported types are skipped
+func parseJWKS(data []byte) ([]jwksKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make([]jwksKey, 0, len(set.Keys))
+	for i, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		parsed, err := parseJWK(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %s", i, err.Error())
+		}
+		if parsed != nil {
+			keys = append(keys, jwksKey{kid: key.Kid, alg: key.Alg, key: parsed})
+		}
+	}
+	return keys, nil
+}
+
+// parseJWK parses the key, nil if its type is not supported
+func parseJWK(key jwk) (interface{}, error) {
+	switch key.Kty {
+	case "oct":
+		secret, err := decodeBase64(key.K)
+		if err != nil || len(secret) == 0 {
+			return nil, fmt.Errorf("invalid symmetric key")
+		}
+		return secret, nil
+	case "RSA":
+		n, errN := decodeBase64(key.N)
+		e, errE := decodeBase64(key.E)
+		if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid RSA key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if key.Crv != "P-256" {
+			return nil, nil
+		}
+		x, errX := decodeBase64(key.X)
+		y, errY := decodeBase64(key.Y)
+		if errX != nil || errY != nil {
+			return nil, fmt.Errorf("invalid EC key")
+		}
+		publicKey := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !publicKey.Curve.IsOnCurve(publicKey.X, publicKey.Y) {
+			return nil, fmt.Errorf("invalid EC key")
+		}
+		return publicKey, nil
+	}
+	return nil, nil
+}
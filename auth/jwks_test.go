@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// b64url returns the base64url encoding of the data, without padding
+func b64url(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+var (
+	testRSAJWK = map[string]string{"kty": "RSA", "kid": "rsa", "n": b64url(testRSAKey.N.Bytes()), "e": b64url(big.NewInt(int64(testRSAKey.E)).Bytes())}
+	testECJWK  = map[string]string{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64url(testECKey.X.FillBytes(make([]byte, 32))), "y": b64url(testECKey.Y.FillBytes(make([]byte, 32)))}
+	testOctJWK = map[string]string{"kty": "oct", "kid": "oct", "k": b64url(testSecret)}
+)
+
+// writeJWKS writes the key set to the file
+func writeJWKS(t *testing.T, path string, keys ...map[string]string) {
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// with returns a copy of the key with the given member set
+func with(key map[string]string, name, value string) map[string]string {
+	copied := make(map[string]string, len(key)+1)
+	for k, v := range key {
+		copied[k] = v
+	}
+	copied[name] = value
+	return copied
+}
+
+func TestParseJWKS(t *testing.T) {
+	tests := []struct {
+		name  string
+		keys  []map[string]string
+		count int
+		valid bool
+	}{
+		{"all types", []map[string]string{testRSAJWK, testECJWK, testOctJWK}, 3, true},
+		{"encryption key skipped", []map[string]string{with(testRSAJWK, "use", "enc"), testECJWK}, 1, true},
+		{"unsupported type skipped", []map[string]string{{"kty": "OKP", "crv": "Ed25519", "x": "AA"}}, 0, true},
+		{"unsupported curve skipped", []map[string]string{with(testECJWK, "crv", "P-384")}, 0, true},
+		{"invalid rsa modulus", []map[string]string{with(testRSAJWK, "n", "")}, 0, false},
+		{"invalid rsa exponent", []map[string]string{with(testRSAJWK, "e", b64url(make([]byte, 5)))}, 0, false},
+		{"point off the curve", []map[string]string{with(testECJWK, "y", testECJWK["x"])}, 0, false},
+		{"empty symmetric key", []map[string]string{with(testOctJWK, "k", "")}, 0, false},
+	}
+	for _, test := range tests {
+		data, _ := json.Marshal(map[string]interface{}{"keys": test.keys})
+		keys, err := parseJWKS(data)
+		if (err == nil) != test.valid || len(keys) != test.count {
+			t.Errorf("%s: expected %d keys, valid %t, got %d keys, %v", test.name, test.count, test.valid, len(keys), err)
+		}
+	}
+	if _, err := parseJWKS([]byte("not json")); err == nil {
+		t.Error("expected an error for an invalid key set")
+	}
+}
+
+func TestJWKSKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, testRSAJWK, testECJWK, testOctJWK, with(with(testOctJWK, "kid", "oct2"), "alg", "HS256"))
+	authenticate := newJWT(t, JWTOptions{JWKSFile: path})
+	claims := map[string]interface{}{"sub": "alice"}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"rs256 by kid", signToken(t, "RS256", "rsa", testRSAKey, claims), true},
+		{"es256 by kid", signToken(t, "ES256", "ec", testECKey, claims), true},
+		{"hs256 by kid", signToken(t, "HS256", "oct", testSecret, claims), true},
+		{"rs256 without kid", signToken(t, "RS256", "", testRSAKey, claims), true},
+		{"hs256 without kid is ambiguous", signToken(t, "HS256", "", testSecret, claims), false},
+		{"unknown kid", signToken(t, "RS256", "other", testRSAKey, claims), false},
+		{"kid of another key type", signToken(t, "ES256", "rsa", testECKey, claims), false},
+		{"hs256 with the rsa modulus as secret", signToken(t, "HS256", "rsa", testRSAKey.N.Bytes(), claims), false},
+		{"alg none", encodeSegment(t, map[string]string{"alg": "none", "kid": "oct"}) + "." + encodeSegment(t, claims) + ".", false},
+	}
+	for _, test := range tests {
+		_, _, err := authenticate(context.Background(), bearer(test.token))
+		if (err == nil) != test.valid {
+			t.Errorf("%s: expected valid %t, got %v", test.name, test.valid, err)
+		}
+	}
+}
+
+func TestJWKSReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, testRSAJWK)
+	jwks, err := newJWKSFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// forcing the next lookup to check the file, and its modification time to differ
+	touch := func() {
+		jwks.file.mu.Lock()
+		jwks.file.lastCheck = time.Time{}
+		jwks.file.mu.Unlock()
+		later := time.Now().Add(time.Hour)
+		if err := os.Chtimes(path, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := jwks.key("ec", "ES256"); err == nil {
+		t.Fatal("expected no key before the reload")
+	}
+	writeJWKS(t, path, testRSAJWK, testECJWK)
+	touch()
+	if _, err := jwks.key("ec", "ES256"); err != nil {
+		t.Fatalf("expected the added key after the reload, got %s", err.Error())
+	}
+
+	if err := os.WriteFile(path, []byte("not json"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	touch()
+	if _, err := jwks.key("ec", "ES256"); err != nil {
+		t.Fatalf("expected the keys loaded last to be kept, got %s", err.Error())
+	}
+}
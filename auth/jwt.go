@@ -0,0 +1,334 @@
+// Package auth provides ready made authenticators for charon routes, to be used with
+// charon.AuthenticateWith or as the IsAuthenticated of route handlers
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/charon"
+	"github.com/charon/errors"
+)
+
+// JWTOptions configures the JWT bearer authenticator, see NewJWTAuthenticator
+type JWTOptions struct {
+	// Secret the key of the HS256 tokens
+	Secret []byte
+
+	// PublicKey the key of the RS256 tokens, an *rsa.PublicKey, or of the ES256 tokens, an *ecdsa.PublicKey
+	PublicKey crypto.PublicKey
+
+	// JWKSFile the path of a JSON Web Key Set file, the keys are picked by the "kid" of the tokens.
+	// The file is reloaded when it changes, the keys loaded last are kept if it becomes invalid
+	JWKSFile string
+
+	// Issuer, Audience the "iss" and "aud" the tokens must hold, not checked if empty
+	Issuer   string
+	Audience string
+
+	// ClockSkew the leeway given when checking the "exp" and "nbf" of the tokens
+	ClockSkew time.Duration
+
+	// Realm the realm of the WWW-Authenticate challenge sent with the failures
+	Realm string
+}
+
+// maxNumericDate the latest NumericDate claim accepted, 9999-12-31T23:59:59Z
+const maxNumericDate = 253402300799
+
+// jwtAuthenticator verifies the bearer tokens of the requests
+type jwtAuthenticator struct {
+	options JWTOptions
+	jwks    *jwksFile
+}
+
+// jwtHeader the JOSE header of a token
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// NewJWTAuthenticator returns the authentication verifying the JWT of the "Authorization: Bearer" header of
+// the requests. The HS256, RS256 and ES256 algorithms are supported, the "exp" and "nbf" claims are checked
+// when present, and the "iss" and "aud" ones as per the options. The principal of the request, see
+// charon.PrincipalFromContext, holds the "sub" claim as its subject, the "roles" claim as its roles, the
+// "scope" or "scp" claim as its scopes, and all the claims. Returns an error if no key is configured or
+// the JWKS file cannot be loaded
+func NewJWTAuthenticator(jwtOptions JWTOptions) (charon.AuthenticateFunc, error) {
+	authenticator := &jwtAuthenticator{options: jwtOptions}
+	switch key := jwtOptions.PublicKey.(type) {
+	case nil, *rsa.PublicKey:
+	case *ecdsa.PublicKey:
+		if key.Curve.Params().Name != "P-256" {
+			return nil, fmt.Errorf("charon/auth: unsupported curve %s, only P-256 is supported", key.Curve.Params().Name)
+		}
+	default:
+		return nil, fmt.Errorf("charon/auth: unsupported public key type %T", jwtOptions.PublicKey)
+	}
+	if jwtOptions.JWKSFile != "" {
+		jwks, err := newJWKSFile(jwtOptions.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		authenticator.jwks = jwks
+	} else if len(jwtOptions.Secret) == 0 && jwtOptions.PublicKey == nil {
+		return nil, fmt.Errorf("charon/auth: one of Secret, PublicKey or JWKSFile is required")
+	}
+	return authenticator.authenticate, nil
+}
+
+// authenticate verifies the bearer token of the request
+func (authenticator *jwtAuthenticator) authenticate(ctx context.Context, header http.Header) (context.Context, *url.Userinfo, errors.Error) {
+	authorization := header.Get("Authorization")
+	if len(authorization) < 7 || !strings.EqualFold(authorization[:7], "Bearer ") {
+		return nil, nil, errors.AuthenticationError{
+			Err:       "Missing bearer token",
+			Mess:      "Unauthorized",
+			Challenge: challenge("Bearer", authenticator.options.Realm, ""),
+		}
+	}
+
+	claims, err := authenticator.verify(strings.TrimSpace(authorization[7:]))
+	if err != nil {
+		return nil, nil, errors.AuthenticationError{
+			Err:       "Invalid bearer token : " + err.Error(),
+			Mess:      "Unauthorized",
+			Challenge: challenge("Bearer", authenticator.options.Realm, `error="invalid_token", error_description="`+strings.ReplaceAll(err.Error(), `"`, `'`)+`"`),
+		}
+	}
+
+	principal := newPrincipal(claims)
+	var userInfo *url.Userinfo
+	if principal.Subject != "" {
+		userInfo = url.User(principal.Subject)
+	}
+	return charon.WithPrincipal(ctx, principal), userInfo, nil
+}
+
+// verify verifies the signature and the claims of the token, returning its claims
+func (authenticator *jwtAuthenticator) verify(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("malformed token header")
+	}
+	signature, err := decodeBase64(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed token signature")
+	}
+	key, err := authenticator.key(header)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("malformed token claims")
+	}
+	if err := authenticator.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// key returns the key to verify the token with, as per its algorithm and key id
+func (authenticator *jwtAuthenticator) key(header jwtHeader) (interface{}, error) {
+	if authenticator.jwks != nil {
+		return authenticator.jwks.key(header.Kid, header.Alg)
+	}
+	switch header.Alg {
+	case "HS256":
+		if len(authenticator.options.Secret) > 0 {
+			return authenticator.options.Secret, nil
+		}
+	case "RS256":
+		if key, ok := authenticator.options.PublicKey.(*rsa.PublicKey); ok {
+			return key, nil
+		}
+	case "ES256":
+		if key, ok := authenticator.options.PublicKey.(*ecdsa.PublicKey); ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported algorithm %q", header.Alg)
+}
+
+// verifySignature verifies the signature of the signed content with the key, as per the algorithm
+func verifySignature(alg string, key interface{}, signed, signature []byte) error {
+	digest := sha256.Sum256(signed)
+	switch alg {
+	case "HS256":
+		secret, ok := key.([]byte)
+		if !ok {
+			break
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	case "RS256":
+		publicKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			break
+		}
+		if rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature) != nil {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	case "ES256":
+		publicKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			break
+		}
+		if len(signature) != 64 {
+			return fmt.Errorf("invalid signature")
+		}
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(publicKey, digest[:], r, s) {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported algorithm %q", alg)
+}
+
+// checkClaims checks the validity period, issuer and audience of the token
+func (authenticator *jwtAuthenticator) checkClaims(claims map[string]interface{}) error {
+	now := time.Now()
+	skew := authenticator.options.ClockSkew
+	if exp, ok, err := numericDate(claims, "exp"); err != nil {
+		return err
+	} else if ok && !now.Before(exp.Add(skew)) {
+		return fmt.Errorf("token expired")
+	}
+	if nbf, ok, err := numericDate(claims, "nbf"); err != nil {
+		return err
+	} else if ok && now.Add(skew).Before(nbf) {
+		return fmt.Errorf("token not valid yet")
+	}
+
+	if issuer := authenticator.options.Issuer; issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != issuer {
+			return fmt.Errorf("invalid issuer")
+		}
+	}
+	if audience := authenticator.options.Audience; audience != "" {
+		if !contains(stringList(claims["aud"]), audience) {
+			return fmt.Errorf("invalid audience")
+		}
+	}
+	return nil
+}
+
+// numericDate returns the time of the named NumericDate claim, and whether it is present
+func numericDate(claims map[string]interface{}, name string) (time.Time, bool, error) {
+	value, ok := claims[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	number, ok := value.(json.Number)
+	if !ok {
+		return time.Time{}, false, fmt.Errorf("invalid %s claim", name)
+	}
+	seconds, err := number.Float64()
+	if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) || math.Abs(seconds) > maxNumericDate {
+		return time.Time{}, false, fmt.Errorf("invalid %s claim", name)
+	}
+	secs, fraction := math.Modf(seconds)
+	return time.Unix(int64(secs), int64(fraction*float64(time.Second))), true, nil
+}
+
+// newPrincipal returns the principal holding the claims of the token
+func newPrincipal(claims map[string]interface{}) *charon.Principal {
+	principal := &charon.Principal{Roles: stringList(claims["roles"]), Claims: claims}
+	principal.Subject, _ = claims["sub"].(string)
+	if scope, ok := claims["scope"].(string); ok {
+		principal.Scopes = strings.Fields(scope)
+	} else if scp, ok := claims["scp"].(string); ok {
+		principal.Scopes = strings.Fields(scp)
+	} else {
+		principal.Scopes = stringList(claims["scp"])
+	}
+	return principal
+}
+
+// stringList returns the claim holding a string or an array of strings as a list
+func stringList(claim interface{}) []string {
+	switch value := claim.(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		list := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// decodeSegment decodes the base64url encoded JSON segment of a token
+func decodeSegment(segment string, v interface{}) error {
+	data, err := decodeBase64(segment)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// decodeBase64 decodes the base64url data, with or without padding
+func decodeBase64(data string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(data, "="))
+}
+
+// challenge returns the WWW-Authenticate challenge of the scheme for the realm, with the extra params if any
+func challenge(scheme, realm, params string) string {
+	value := scheme
+	if realm != "" {
+		value += ` realm="` + strings.ReplaceAll(realm, `"`, `'`) + `"`
+	}
+	if params != "" {
+		if realm != "" {
+			value += ","
+		}
+		value += " " + params
+	}
+	return value
+}
+
+// contains checks if the values hold the value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
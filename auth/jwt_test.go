@@ -0,0 +1,311 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charon"
+	"github.com/charon/errors"
+)
+
+var (
+	testSecret = []byte("0123456789abcdef0123456789abcdef")
+	testRSAKey = mustKey(rsa.GenerateKey(rand.Reader, 2048)).(*rsa.PrivateKey)
+	testECKey  = mustKey(ecdsa.GenerateKey(elliptic.P256(), rand.Reader)).(*ecdsa.PrivateKey)
+)
+
+// mustKey returns the generated key, panics if it could not be generated
+func mustKey(key interface{}, err error) interface{} {
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
+
+// encodeSegment returns the base64url encoding of the JSON of the value
+func encodeSegment(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// signToken returns the token of the claims signed with the key as per the algorithm
+func signToken(t *testing.T, alg, kid string, key interface{}, claims map[string]interface{}) string {
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	signed := encodeSegment(t, header) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	var signature []byte
+	switch alg {
+	case "HS256":
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case "RS256":
+		var err error
+		if signature, err = rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, digest[:]); err != nil {
+			t.Fatal(err)
+		}
+	case "ES256":
+		r, s, err := ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// bearer returns the request header holding the token
+func bearer(token string) http.Header {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+token)
+	return header
+}
+
+// newJWT returns the JWT authenticator for the options, failing the test if it cannot be created
+func newJWT(t *testing.T, jwtOptions JWTOptions) charon.AuthenticateFunc {
+	authenticate, err := NewJWTAuthenticator(jwtOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authenticate
+}
+
+func TestNewJWTAuthenticator(t *testing.T) {
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		jwtOptions JWTOptions
+		valid      bool
+	}{
+		{"secret", JWTOptions{Secret: testSecret}, true},
+		{"rsa", JWTOptions{PublicKey: &testRSAKey.PublicKey}, true},
+		{"ec", JWTOptions{PublicKey: &testECKey.PublicKey}, true},
+		{"no key", JWTOptions{}, false},
+		{"p384", JWTOptions{PublicKey: &p384.PublicKey}, false},
+		{"private key", JWTOptions{PublicKey: testRSAKey}, false},
+		{"missing jwks", JWTOptions{JWKSFile: "/nonexistent/jwks.json"}, false},
+	}
+	for _, test := range tests {
+		if _, err := NewJWTAuthenticator(test.jwtOptions); (err == nil) != test.valid {
+			t.Errorf("%s: expected valid %t, got %v", test.name, test.valid, err)
+		}
+	}
+}
+
+func TestJWTSignatures(t *testing.T) {
+	claims := map[string]interface{}{"sub": "alice"}
+	publicDER, err := x509.MarshalPKIXPublicKey(&testRSAKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+	valid := signToken(t, "HS256", "", testSecret, claims)
+	parts := strings.Split(valid, ".")
+	esParts := strings.Split(signToken(t, "ES256", "", testECKey, claims), ".")
+	esSignature, _ := base64.RawURLEncoding.DecodeString(esParts[2])
+	esTruncated := esParts[0] + "." + esParts[1] + "." + base64.RawURLEncoding.EncodeToString(esSignature[:63])
+
+	hs256 := newJWT(t, JWTOptions{Secret: testSecret})
+	rs256 := newJWT(t, JWTOptions{PublicKey: &testRSAKey.PublicKey})
+	es256 := newJWT(t, JWTOptions{PublicKey: &testECKey.PublicKey})
+
+	tests := []struct {
+		name         string
+		authenticate charon.AuthenticateFunc
+		token        string
+		valid        bool
+	}{
+		{"hs256", hs256, valid, true},
+		{"hs256 padded", hs256, valid + "==", true},
+		{"hs256 wrong secret", hs256, signToken(t, "HS256", "", []byte("other"), claims), false},
+		{"hs256 tampered claims", hs256, parts[0] + "." + encodeSegment(t, map[string]interface{}{"sub": "admin"}) + "." + parts[2], false},
+		{"hs256 no signature", hs256, parts[0] + "." + parts[1] + ".", false},
+		{"alg none", hs256, encodeSegment(t, map[string]string{"alg": "none"}) + "." + parts[1] + ".", false},
+		{"alg None", hs256, encodeSegment(t, map[string]string{"alg": "None"}) + "." + parts[1] + ".", false},
+		{"rs256", rs256, signToken(t, "RS256", "", testRSAKey, claims), true},
+		{"rs256 on hs256", hs256, signToken(t, "RS256", "", testRSAKey, claims), false},
+		{"hs256 with the rsa public key", rs256, signToken(t, "HS256", "", publicPEM, claims), false},
+		{"hs256 with the rsa public key der", rs256, signToken(t, "HS256", "", publicDER, claims), false},
+		{"es256", es256, signToken(t, "ES256", "", testECKey, claims), true},
+		{"es256 on rs256", rs256, signToken(t, "ES256", "", testECKey, claims), false},
+		{"rs256 on es256", es256, signToken(t, "RS256", "", testRSAKey, claims), false},
+		{"es256 truncated signature", es256, esTruncated, false},
+		{"two segments", hs256, parts[0] + "." + parts[1], false},
+		{"four segments", hs256, valid + ".x", false},
+		{"invalid base64", hs256, parts[0] + ".!!." + parts[2], false},
+		{"invalid header", hs256, encodeSegment(t, "not a header") + "." + parts[1] + "." + parts[2], false},
+	}
+	for _, test := range tests {
+		_, _, err := test.authenticate(context.Background(), bearer(test.token))
+		if (err == nil) != test.valid {
+			t.Errorf("%s: expected valid %t, got %v", test.name, test.valid, err)
+		}
+		if err != nil && err.StatusCode() != http.StatusUnauthorized {
+			t.Errorf("%s: expected status %d, got %d", test.name, http.StatusUnauthorized, err.StatusCode())
+		}
+	}
+}
+
+func TestJWTClaims(t *testing.T) {
+	now := time.Now().Unix()
+	tests := []struct {
+		name   string
+		skew   time.Duration
+		claims map[string]interface{}
+		valid  bool
+	}{
+		{"no claims", 0, map[string]interface{}{}, true},
+		{"valid period", 0, map[string]interface{}{"nbf": now - 30, "exp": now + 30}, true},
+		{"expired", 0, map[string]interface{}{"exp": now - 30}, false},
+		{"expired within skew", time.Minute, map[string]interface{}{"exp": now - 30}, true},
+		{"expired past skew", time.Minute, map[string]interface{}{"exp": now - 90}, false},
+		{"not valid yet", 0, map[string]interface{}{"nbf": now + 30}, false},
+		{"not valid yet within skew", time.Minute, map[string]interface{}{"nbf": now + 30}, true},
+		{"not valid yet past skew", time.Minute, map[string]interface{}{"nbf": now + 90}, false},
+		{"fractional exp", 0, map[string]interface{}{"exp": float64(now) + 30.5}, true},
+		{"far nbf", 0, map[string]interface{}{"nbf": 1e12}, false},
+		{"overflowing nbf", 0, map[string]interface{}{"nbf": 9e18}, false},
+		{"overflowing exp", 0, map[string]interface{}{"exp": -9e18}, false},
+		{"string exp", 0, map[string]interface{}{"exp": "tomorrow"}, false},
+		{"null nbf", 0, map[string]interface{}{"nbf": nil}, false},
+	}
+	for _, test := range tests {
+		authenticate := newJWT(t, JWTOptions{Secret: testSecret, ClockSkew: test.skew})
+		_, _, err := authenticate(context.Background(), bearer(signToken(t, "HS256", "", testSecret, test.claims)))
+		if (err == nil) != test.valid {
+			t.Errorf("%s: expected valid %t, got %v", test.name, test.valid, err)
+		}
+	}
+}
+
+func TestJWTIssuerAudience(t *testing.T) {
+	authenticate := newJWT(t, JWTOptions{Secret: testSecret, Issuer: "https://issuer", Audience: "api"})
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		valid  bool
+	}{
+		{"audience string", map[string]interface{}{"iss": "https://issuer", "aud": "api"}, true},
+		{"audience list", map[string]interface{}{"iss": "https://issuer", "aud": []string{"web", "api"}}, true},
+		{"other audience", map[string]interface{}{"iss": "https://issuer", "aud": []string{"web"}}, false},
+		{"missing audience", map[string]interface{}{"iss": "https://issuer"}, false},
+		{"other issuer", map[string]interface{}{"iss": "https://other", "aud": "api"}, false},
+		{"missing issuer", map[string]interface{}{"aud": "api"}, false},
+		{"issuer not a string", map[string]interface{}{"iss": []string{"https://issuer"}, "aud": "api"}, false},
+	}
+	for _, test := range tests {
+		_, _, err := authenticate(context.Background(), bearer(signToken(t, "HS256", "", testSecret, test.claims)))
+		if (err == nil) != test.valid {
+			t.Errorf("%s: expected valid %t, got %v", test.name, test.valid, err)
+		}
+	}
+}
+
+func TestJWTPrincipal(t *testing.T) {
+	authenticate := newJWT(t, JWTOptions{Secret: testSecret})
+	tests := []struct {
+		claims map[string]interface{}
+		scopes []string
+	}{
+		{map[string]interface{}{"sub": "alice", "roles": []string{"admin"}, "scope": "read write"}, []string{"read", "write"}},
+		{map[string]interface{}{"sub": "alice", "roles": "admin", "scp": "read write"}, []string{"read", "write"}},
+		{map[string]interface{}{"sub": "alice", "roles": []string{"admin"}, "scp": []string{"read", "write"}}, []string{"read", "write"}},
+	}
+	for _, test := range tests {
+		ctx, userInfo, err := authenticate(context.Background(), bearer(signToken(t, "HS256", "", testSecret, test.claims)))
+		if err != nil {
+			t.Fatalf("%v: %s", test.claims, err.Error())
+		}
+		principal := charon.PrincipalFromContext(ctx)
+		if principal == nil || principal.Subject != "alice" || !principal.HasRole("admin") || userInfo.Username() != "alice" {
+			t.Fatalf("%v: unexpected principal %+v", test.claims, principal)
+		}
+		for _, scope := range test.scopes {
+			if !principal.HasScope(scope) {
+				t.Errorf("%v: expected scope %s, got %v", test.claims, scope, principal.Scopes)
+			}
+		}
+		if principal.Claim("sub") != "alice" {
+			t.Errorf("%v: expected the sub claim, got %v", test.claims, principal.Claim("sub"))
+		}
+	}
+}
+
+func TestJWTChallenge(t *testing.T) {
+	authenticate := newJWT(t, JWTOptions{Secret: testSecret, Realm: "api"})
+	tests := []struct {
+		authorization string
+		challenge     string
+	}{
+		{"", `Bearer realm="api"`},
+		{"Basic YWxpY2U6cHc=", `Bearer realm="api"`},
+		{"Bearer a.b", `Bearer realm="api", error="invalid_token", error_description="malformed token"`},
+	}
+	for _, test := range tests {
+		header := http.Header{}
+		if test.authorization != "" {
+			header.Set("Authorization", test.authorization)
+		}
+		_, _, err := authenticate(context.Background(), header)
+		authErr, ok := err.(errors.AuthenticationError)
+		if !ok || authErr.Challenge != test.challenge || authErr.StatusCode() != http.StatusUnauthorized {
+			t.Errorf("%q: expected challenge %q, got %#v", test.authorization, test.challenge, err)
+		}
+	}
+}
+
+func TestNumericDate(t *testing.T) {
+	tests := []struct {
+		value   interface{}
+		want    time.Time
+		present bool
+		valid   bool
+	}{
+		{json.Number("1700000000"), time.Unix(1700000000, 0), true, true},
+		{json.Number("1700000000.5"), time.Unix(1700000000, 500000000), true, true},
+		{json.Number("253402300799"), time.Unix(253402300799, 0), true, true},
+		{json.Number("253402300800"), time.Time{}, false, false},
+		{json.Number("1e12"), time.Time{}, false, false},
+		{json.Number("9e18"), time.Time{}, false, false},
+		{json.Number("-9e18"), time.Time{}, false, false},
+		{json.Number("1e400"), time.Time{}, false, false},
+		{"1700000000", time.Time{}, false, false},
+		{nil, time.Time{}, false, true},
+	}
+	for _, test := range tests {
+		claims := map[string]interface{}{}
+		if test.value != nil {
+			claims["nbf"] = test.value
+		}
+		got, present, err := numericDate(claims, "nbf")
+		if (err == nil) != test.valid || present != test.present || !got.Equal(test.want) {
+			t.Errorf("%v: expected %v, %t, valid %t, got %v, %t, %v", test.value, test.want, test.present, test.valid, got, present, err)
+		}
+	}
+}
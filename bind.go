@@ -0,0 +1,136 @@
+package charon
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/charon/errors"
+)
+
+//Bind decodes the body of the incoming http request into the struct pointed to by v, and validates it
+//as per the `validate` tags of its fields, see Validate. Bodies are decoded with the codec registered for
+//their Content-Type, see WithCodec, JSON by default. Query params and form values are set on the fields
+//named as per their `json` tags, converted to the field type.
+//Returns an errors.InvalidInputError listing each invalid field
+func (detail RouteDetails) Bind(v interface{}) errors.Error {
+	target := reflect.ValueOf(v)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return errors.InternalError{Err: fmt.Sprintf("charon: Bind requires a non nil pointer, got %T", v)}
+	}
+
+	if detail.values != nil {
+		if err := bindValues(detail.values, target.Elem()); err != nil {
+			return err
+		}
+	} else if len(detail.rawBody) > 0 {
+		codec := detail.reqCodec
+		if codec == nil {
+			codec = jsonCodec{}
+		}
+		if err := codec.Decode(detail.rawBody, v); err != nil {
+			return decodeError(err)
+		}
+	}
+	return Validate(v)
+}
+
+// bindValues sets the form values on the fields of the struct, collecting the values that could not be converted
+func bindValues(values url.Values, target reflect.Value) errors.Error {
+	if target.Kind() != reflect.Struct {
+		return errors.InternalError{Err: fmt.Sprintf("charon: cannot bind form values into %s", target.Type())}
+	}
+
+	var fieldErrs []errors.FieldError
+	targetType := target.Type()
+	for i := 0; i < targetType.NumField(); i++ {
+		structField := targetType.Field(i)
+		if structField.PkgPath != "" {
+			continue
+		}
+		name := fieldName(structField)
+		if name == "" {
+			continue
+		}
+		fieldValues, ok := values[name]
+		if !ok || len(fieldValues) == 0 {
+			continue
+		}
+		if err := setField(target.Field(i), fieldValues); err != nil {
+			fieldErrs = append(fieldErrs, errors.FieldError{Field: name, Reason: err.Error()})
+		}
+	}
+	return newValidationError(fieldErrs)
+}
+
+// setField converts the values to the type of the field and sets them
+func setField(field reflect.Value, values []string) error {
+	switch field.Kind() {
+	case reflect.Ptr:
+		elem := reflect.New(field.Type().Elem())
+		if err := setField(elem.Elem(), values); err != nil {
+			return err
+		}
+		field.Set(elem)
+		return nil
+	case reflect.Slice:
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setScalar(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+	return setScalar(field, values[0])
+}
+
+// setScalar converts the value to the type of the field and sets it
+func setScalar(field reflect.Value, value string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("must be a boolean")
+		}
+		field.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+		field.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be a positive integer")
+		}
+		field.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be a number")
+		}
+		field.SetFloat(parsed)
+	default:
+		return fmt.Errorf("cannot be set from a form value")
+	}
+	return nil
+}
+
+// fieldName returns the name of the field as per its json tag, empty if the field is ignored
+func fieldName(structField reflect.StructField) string {
+	tag := structField.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name
+	}
+	return structField.Name
+}
@@ -0,0 +1,176 @@
+package charon
+
+import (
+	"bytes"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+
+	"github.com/charon/errors"
+)
+
+// defaultMultipartMemory the size of the multipart/form-data bodies kept in memory, the same as the net/http one
+const defaultMultipartMemory = 32 << 20
+
+// WithMultipartMemory sets the size of a multipart/form-data body kept in memory, the files past it
+// are stored in temporary files for the duration of the request. Defaults to 32 MB
+func WithMultipartMemory(maxMemory int64) Option {
+	return func(server *Server) {
+		server.multipartMemory = maxMemory
+	}
+}
+
+// WithMaxBodySize sets the maximum size of the request bodies in bytes, requests with larger bodies
+// are answered with 413 Payload Too Large. PathDetail.MaxBodySize overrides it per route.
+// Defaults to no limit, except for the compressed bodies whose decoded size is limited to 10 MB
+func WithMaxBodySize(maxBodySize int64) Option {
+	return func(server *Server) {
+		server.maxBodySize = maxBodySize
+	}
+}
+
+// bodyLimit returns the maximum body size for the route, zero or negative if there is no limit.
+// Decoded bodies are limited to defaultDecodedBodySize when no limit is set
+func (server *Server) bodyLimit(rt *route, decoded bool) int64 {
+	limit := server.maxBodySize
+	if rt.detail.MaxBodySize != 0 {
+		limit = rt.detail.MaxBodySize
+	}
+	if limit == 0 && decoded {
+		return defaultDecodedBodySize
+	}
+	return limit
+}
+
+// bodyError returns the error for a failure to read the body, the PayloadTooLargeError if the body
+// is over the limit, otherwise the given error
+func bodyError(err error, otherwise errors.Error) errors.Error {
+	var maxBytesErr *http.MaxBytesError
+	if stderrors.As(err, &maxBytesErr) {
+		return errors.PayloadTooLargeError{Err: fmt.Sprint("Request body exceeds the limit of ", maxBytesErr.Limit, " bytes")}
+	}
+	return otherwise
+}
+
+// FormFile a file uploaded with a multipart/form-data request
+type FormFile struct {
+	Field       string
+	Filename    string
+	Size        int64
+	ContentType string
+	Header      textproto.MIMEHeader
+
+	fileHeader *multipart.FileHeader
+}
+
+// Open opens the uploaded file for reading, the file is only available for the duration of the request
+func (file *FormFile) Open() (multipart.File, error) {
+	return file.fileHeader.Open()
+}
+
+//Files returns the files uploaded with the multipart/form-data request, keyed by form field
+func (detail RouteDetails) Files() map[string][]*FormFile {
+	return detail.files
+}
+
+//RawBody returns the exact bytes of the body of the incoming http request, nil for GET, HEAD
+//and multipart/form-data requests
+func (detail RouteDetails) RawBody() []byte {
+	return detail.rawBody
+}
+
+//File returns the first file uploaded against the given form field, nil if not present
+func (detail RouteDetails) File(field string) *FormFile {
+	if files := detail.files[field]; len(files) > 0 {
+		return files[0]
+	}
+	return nil
+}
+
+// readBody reads the body of the request as per its Content-Type. For GET and HEAD requests the
+// query params are used as the body. Form values are set as the query params, see QueryOptions.
+// Other bodies are decoded with the codec registered for their media type, JSON if not set,
+// the UnsupportedMediaTypeError is returned if there is no such codec.
+// The raw body, except for multipart bodies, and the form values are kept on the route details
+func (server *Server) readBody(req *http.Request, rDetails *RouteDetails) (map[string]interface{}, errors.Error) {
+	body := make(map[string]interface{})
+
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		// body = req.URL.Query()
+		rDetails.values = req.URL.Query()
+		return server.queryOptions.toBody(rDetails.values)
+	}
+
+	mediaType := ""
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		var err error
+		if mediaType, _, err = mime.ParseMediaType(contentType); err != nil {
+			return nil, errors.UnsupportedMediaTypeError{Err: fmt.Sprint("Invalid Content-Type ", contentType, " : ", err.Error())}
+		}
+	}
+
+	if mediaType == "multipart/form-data" {
+		if err := req.ParseMultipartForm(server.multipartMemory); err != nil {
+			return nil, bodyError(err, errors.InvalidInputError{Err: err.Error(), Mess: "Invalid multipart body"})
+		}
+		rDetails.values = req.MultipartForm.Value
+		body, err := server.queryOptions.toBody(rDetails.values)
+		if err != nil {
+			return nil, err
+		}
+		if len(req.MultipartForm.File) > 0 {
+			rDetails.files = make(map[string][]*FormFile, len(req.MultipartForm.File))
+			for field, headers := range req.MultipartForm.File {
+				for _, header := range headers {
+					rDetails.files[field] = append(rDetails.files[field], &FormFile{
+						Field:       field,
+						Filename:    header.Filename,
+						Size:        header.Size,
+						ContentType: header.Header.Get("Content-Type"),
+						Header:      header.Header,
+						fileHeader:  header,
+					})
+				}
+			}
+		}
+		return body, nil
+	}
+
+	raw, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, bodyError(err, errors.InvalidInputError{Err: err.Error(), Mess: "Invalid request body"})
+	}
+	rDetails.rawBody = raw
+	// the body stays readable for the handlers using the *http.Request
+	req.Body = io.NopCloser(bytes.NewReader(raw))
+
+	if mediaType == "application/x-www-form-urlencoded" {
+		values, err := url.ParseQuery(string(raw))
+		if err != nil {
+			return nil, errors.InvalidInputError{Err: err.Error(), Mess: "Invalid form body"}
+		}
+		rDetails.values = values
+		return server.queryOptions.toBody(values)
+	}
+
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, nil
+	}
+	codec := server.codecs[defaultMediaType]
+	if mediaType != "" {
+		codec = server.codecs.lookup(mediaType)
+	}
+	if codec == nil {
+		return nil, errors.UnsupportedMediaTypeError{Err: fmt.Sprint("No codec registered for the media type ", mediaType)}
+	}
+	rDetails.reqCodec = codec
+	if err = codec.Decode(raw, &body); err != nil {
+		return nil, decodeError(err)
+	}
+	return body, nil
+}
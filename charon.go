@@ -2,7 +2,6 @@ package charon
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -12,99 +11,202 @@ import (
 	logr "github.com/charon/logger"
 )
 
-// type ServerHandler interface {
-// 	ServeHTTP(http.ResponseWriter, *http.Request)
-// }
-
-type charonServerHandler struct {
-	logger       *logr.Logger
-	respHandler  ResponseHandler
-	pathHandlers map[PathDetail]RouteHandler
-}
-
-//ServeRequest method serves all incoming requests
-func (serverHandler *charonServerHandler) ServeRequest(resp http.ResponseWriter, req *http.Request) {
+//ServeHTTP method serves all incoming requests, implementation of http.Handler
+func (server *Server) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 
 	isServed := false
-	found := false
 
 	path := req.URL.Path
 	method := req.Method
 	header := req.Header
 
-	rDetails := RouteDetails{method: method, path: path, headers: header, log: strings.Builder{}}
+	rDetails := RouteDetails{method: method, host: req.Host, path: path, headers: header, query: req.URL.Query(),
+		req: req, clientIP: server.clientIP(req), respHeader: make(http.Header), cookieDefaults: server.cookieDefaults,
+		log: strings.Builder{}}
+	rDetails.respMediaType, rDetails.respCodec = server.codecs.negotiate(header.Get("Accept"))
 
-	serverHandler.logger.LogInfo(fmt.Sprint("Incoming Request  ", method, " : ", path), nil, &rDetails)
+	server.logger.LogInfo(fmt.Sprint("Incoming Request  ", method, " : ", path, " from ", rDetails.clientIP), nil, &rDetails)
+
+	// HEAD requests served by a GET route have their body dropped once the response is complete,
+	// the route is released only after that, so that removing it drains the request completely
+	var headResp *headResponseWriter
+	var rt *route
+	defer func() {
+		if headResp != nil {
+			headResp.flush()
+		}
+		if rt != nil {
+			rt.release()
+		}
+	}()
 
 	defer func() {
 		if r := recover(); r != nil {
-			//serverHandler.logger.LogSevere(string(debug.Stack()), nil, &rDetails)
-			serverHandler.logger.LogPanic(r.(string), nil, &rDetails)
+			//server.logger.LogSevere(string(debug.Stack()), nil, &rDetails)
+			server.logger.LogPanic(fmt.Sprint(r), nil, &rDetails)
 			err := errors.InternalError{Err: "Unknown server error"}
-			handleLog(rDetails, serverHandler.logger)
-			handleResponse(resp, nil, err, serverHandler.respHandler)
+			handleLog(rDetails, server.logger)
+			handleResponse(resp, &rDetails, nil, err, server.respHandler)
 		}
 	}()
 
-	body := make(map[string]interface{})
+	routes, matched, hostParams, params, routePath, redirect := server.match(method, req.Host, path)
+	rt = matched
+	if redirect {
+		server.logger.LogInfo(fmt.Sprint("Redirecting to ", routePath), nil, &rDetails)
+		handleLog(rDetails, server.logger)
+		redirectTo(resp, req, routePath)
+		return
+	}
+	rDetails.path = routePath
+	rDetails.hostParams = hostParams
+	if rt != nil && rt.detail.Method != method {
+		headResp = &headResponseWriter{ResponseWriter: resp, status: http.StatusOK}
+		resp = headResp
+	}
+	if rt == nil && routes != nil && method == http.MethodOptions {
+		resp.Header().Set("Allow", strings.Join(routes.allowed(), ", "))
+		resp.WriteHeader(http.StatusNoContent)
+		handleLog(rDetails, server.logger)
+		return
+	}
+	if rt == nil {
+		var err errors.Error
+		if routes == nil {
+			// fmt.Println("Error:  Path not found", "  ", time.Now())
+			server.logger.LogSevere("Path not found", nil, &rDetails)
+			err = errors.NotFoundError{Err: "Path not found", Mess: "Path not found"}
+		} else {
+			allowed := strings.Join(routes.allowed(), ", ")
+			server.logger.LogSevere(fmt.Sprint("Method not allowed, allowed methods : ", allowed), nil, &rDetails)
+			resp.Header().Set("Allow", allowed)
+			err = errors.InvalidMethodError{Err: fmt.Sprint("Method ", method, " not allowed")}
+		}
+		handleLog(rDetails, server.logger)
+		handleResponse(resp, &rDetails, nil, err, server.respHandler)
+		return
+	}
+	rDetails.params = params
 
-	// fmt.Println("Incoming Request  ", method, ":", path, "  ", time.Now())
-	if method == "GET" {
-		// body = req.URL.Query()
-		for k, v := range req.URL.Query() {
-			body[k] = v
+	respHandler := server.respHandler
+	if rt.config.respHandler != nil {
+		respHandler = rt.config.respHandler
+	}
+
+	decoded := false
+	if req.Header.Get("Content-Encoding") != "" {
+		var err errors.Error
+		if decoded, err = server.decodeBody(req); err != nil {
+			server.logger.LogSevere(fmt.Sprint("Error:  ", err.Error()), nil, &rDetails)
+			handleLog(rDetails, server.logger)
+			handleResponse(resp, &rDetails, nil, err, respHandler)
+			return
 		}
-	} else {
-		decoder := json.NewDecoder(req.Body)
-		err := decoder.Decode(&body)
-		if err != nil {
-			if err.Error() == "EOF" {
-				body = nil
-			} else {
-				// fmt.Println("Error:  ", err.Error(), "  ", time.Now())
-				serverHandler.logger.LogSevere(fmt.Sprint("Error:  ", err.Error()), nil, &rDetails)
-				handleLog(rDetails, serverHandler.logger)
-				handleResponse(resp, nil, errors.InternalError{Err: err.Error()}, serverHandler.respHandler)
-				isServed = true
+	}
+	if limit := server.bodyLimit(rt, decoded); limit > 0 {
+		if req.ContentLength > limit {
+			err := errors.PayloadTooLargeError{Err: fmt.Sprint("Request body of ", req.ContentLength, " bytes exceeds the limit of ", limit, " bytes")}
+			server.logger.LogSevere(fmt.Sprint("Error:  ", err.Error()), nil, &rDetails)
+			handleLog(rDetails, server.logger)
+			handleResponse(resp, &rDetails, nil, err, respHandler)
+			return
+		}
+		req.Body = http.MaxBytesReader(resp, req.Body, limit)
+	}
+
+	if rt.httpHandler != nil {
+		if rt.config.authenticate != nil {
+			var auErr errors.Error
+			if req, auErr = authenticate(rt.config.authenticate, req); auErr != nil {
+				server.logger.LogSevere(fmt.Sprint("Error:  ", auErr.Error()), nil, &rDetails)
+				handleLog(rDetails, server.logger)
+				handleResponse(resp, &rDetails, nil, auErr, respHandler)
+				return
 			}
 		}
+		rDetails.ctx = context.WithValue(req.Context(), routeDetailsKey, &rDetails)
+		rDetails.req = req.WithContext(rDetails.ctx)
+		if azErr := authorize(rt.detail.Access, rDetails); azErr != nil {
+			server.logger.LogSevere(fmt.Sprint("Error:  ", azErr.Error()), nil, &rDetails)
+			handleLog(rDetails, server.logger)
+			handleResponse(resp, &rDetails, nil, azErr, respHandler)
+			return
+		}
+		rt.httpHandler.ServeHTTP(resp, rDetails.req)
+		handleLog(rDetails, server.logger)
+		return
+	}
+
+	// fmt.Println("Incoming Request  ", method, ":", path, "  ", time.Now())
+	body, bodyErr := server.readBody(req, &rDetails)
+	if req.MultipartForm != nil {
+		defer req.MultipartForm.RemoveAll()
+	}
+	if bodyErr != nil {
+		// fmt.Println("Error:  ", err.Error(), "  ", time.Now())
+		server.logger.LogSevere(fmt.Sprint("Error:  ", bodyErr.Error()), nil, &rDetails)
+		handleLog(rDetails, server.logger)
+		handleResponse(resp, &rDetails, nil, bodyErr, respHandler)
+		isServed = true
 	}
 
 	rDetails.body = body
 	// var rDetails = RouteDetails{method, path, header, body, req.Context(), strings.Builder{}}
 
 	if !isServed {
-		for pDetail, handler := range serverHandler.pathHandlers {
-
-			if pDetail.PathRegex == rDetails.Path() && rDetails.Method() == pDetail.Method {
-				found = true
-				handledResp, err := HandleRequest(handler, &rDetails, req)
-				if err != nil {
-					serverHandler.logger.LogSevere(fmt.Sprint("Error:  ", err.Error()), nil, &rDetails)
-				}
-				handleLog(rDetails, serverHandler.logger)
-				handleResponse(resp, handledResp, err, serverHandler.respHandler)
-				break
-			}
-		}
-		if !found {
-			// fmt.Println("Error:  Path not found", "  ", time.Now())
-			serverHandler.logger.LogSevere("Path not found", nil, &rDetails)
-			err := errors.AuthenticationError{Err: "Path not found", Mess: "Path not found"}
-			handleLog(rDetails, serverHandler.logger)
-			handleResponse(resp, nil, err, serverHandler.respHandler)
+		rDetails.ctx = req.Context()
+		pipeline := HandlerFunc(func(rDetails *RouteDetails) ([]byte, errors.Error) {
+			return handleRequest(rt.handler, rt.config, rt.detail.Access, rDetails, rDetails.req)
+		})
+		handledResp, err := chain(pipeline, server.middlewares, rt.config.middlewares).HandleCall(&rDetails)
+		if err != nil {
+			server.logger.LogSevere(fmt.Sprint("Error:  ", err.Error()), nil, &rDetails)
 		}
+		handleLog(rDetails, server.logger)
+		handleResponse(resp, &rDetails, handledResp, err, respHandler)
 	}
 }
 
+// contextKey type of the keys of the values charon stores in the request context
+type contextKey int
+
+const (
+	routeDetailsKey contextKey = iota
+	principalKey
+)
+
+//RouteDetailsFromContext returns the route details of the request, for the http.Handlers registered
+//with HandleHTTP, nil if not present
+func RouteDetailsFromContext(ctx context.Context) *RouteDetails {
+	rDetails, _ := ctx.Value(routeDetailsKey).(*RouteDetails)
+	return rDetails
+}
+
 // RouteDetails - route details for the incoming request
 type RouteDetails struct {
-	method  string
-	path    string
-	headers http.Header
-	body    map[string]interface{}
-	ctx     context.Context
-	log     strings.Builder
+	method     string
+	host       string
+	path       string
+	headers    http.Header
+	body       map[string]interface{}
+	rawBody    []byte
+	query      url.Values
+	values     url.Values
+	params     map[string]string
+	hostParams map[string]string
+	clientIP   string
+	files      map[string][]*FormFile
+	ctx        context.Context
+	req        *http.Request
+	log        strings.Builder
+
+	// the codec of the request body, and the one negotiated for the response as per the Accept header
+	reqCodec      Codec
+	respMediaType string
+	respCodec     Codec
+	respHeader    http.Header
+
+	cookieDefaults CookieDefaults
 }
 
 //Method returns the http method for the incoming http request
@@ -112,6 +214,22 @@ func (detail RouteDetails) Method() string {
 	return detail.method
 }
 
+//Host returns the host, as sent in the Host header, for the incoming http request
+func (detail RouteDetails) Host() string {
+	return detail.host
+}
+
+//HostParams returns the labels captured from the host of the incoming http request,
+//the leading labels matched by a "*" wildcard are captured under the "*" name
+func (detail RouteDetails) HostParams() map[string]string {
+	return detail.hostParams
+}
+
+//HostParam returns the value captured for the given host label, empty if not present
+func (detail RouteDetails) HostParam(name string) string {
+	return detail.hostParams[name]
+}
+
 //Path returns the path(uri) for the incoming http request
 func (detail RouteDetails) Path() string {
 	return detail.path
@@ -127,6 +245,31 @@ func (detail RouteDetails) Body() map[string]interface{} {
 	return detail.body
 }
 
+//PathParams returns the params captured from the path of the incoming http request
+func (detail RouteDetails) PathParams() map[string]string {
+	return detail.params
+}
+
+//PathParam returns the value captured for the given path param, empty if not present
+func (detail RouteDetails) PathParam(name string) string {
+	return detail.params[name]
+}
+
+//Query returns the query params of the incoming http request, whatever the http method
+func (detail RouteDetails) Query() url.Values {
+	return detail.query
+}
+
+//Request returns the underlying http request, with the context set by the authentication
+func (detail RouteDetails) Request() *http.Request {
+	return detail.req
+}
+
+//ResponseHeader returns the headers to be sent with the response, set them before returning from HandleCall
+func (detail RouteDetails) ResponseHeader() http.Header {
+	return detail.respHeader
+}
+
 //Context returns the context of the incoming http request
 func (detail RouteDetails) Context() context.Context {
 	return detail.ctx
@@ -145,11 +288,13 @@ func (detail RouteDetails) GetLog() string {
 //NewRouteDetail created and returns a new RouteDetail Obj
 func NewRouteDetail(ctx context.Context, method, path string, header http.Header, body map[string]interface{}, logBldr strings.Builder) *RouteDetails {
 	return &RouteDetails{
-		method, path, header, body, ctx, logBldr,
+		method: method, path: path, headers: header, body: body, ctx: ctx, log: logBldr,
+		respHeader: make(http.Header), cookieDefaults: defaultCookieDefaults,
 	}
 }
 
-// RouteHandler type to be registered with each regex url with cerberus
+// RouteHandler type to be registered with each regex url with cerberus, a Handler implementing
+// Authenticator and Validator as well. Handlers only need to implement the ones they use
 type RouteHandler interface {
 	IsAuthenticated(context.Context, http.Header) (context.Context, *url.Userinfo, errors.Error)
 	IsValidInput(RouteDetails) errors.Error
@@ -158,49 +303,78 @@ type RouteHandler interface {
 }
 
 // PathDetail type to be registered with each regex url with cerberus
+// PathRegex can either be a template like "/users/{id}/orders/{orderId:[0-9]+}" or "/assets/*filepath",
+// or, when starting with "^", a regular expression matching the whole path whose named groups are captured
+// as path params.
+// Host restricts the route to the matching hosts, like "api.example.com", "{tenant}.example.com"
+// or "*.tenant.example.com", routes without a Host are served for any host.
+// MaxBodySize overrides the maximum request body size of the server for the route, a negative value
+// removes the limit.
+// Access declares the roles, scopes or policy required from the principal of the request, see AccessPolicy
 type PathDetail struct {
-	Method    string
-	PathRegex string
+	Method      string
+	PathRegex   string
+	Host        string
+	MaxBodySize int64
+	Access      *AccessPolicy
 }
 
 //ResponseHandler function does response handling in the format specified by the user
 type ResponseHandler func(http.ResponseWriter, []byte, errors.Error)
 
-//AuthenticateAndSetContext does authentication and setting of context adn userinfo
-// takes in header, and request context, returns a new context, UserInfo and Cerberus error if any
-// type AuthenticateAndSetContext func(context.Context, http.Header, string, string) (context.Context, *url.Userinfo, errors.Error)
+//AuthenticateFunc does authentication and setting of context and userinfo
+// takes in request context and header, returns a new context, UserInfo and Cerberus error if any
+type AuthenticateFunc func(context.Context, http.Header) (context.Context, *url.Userinfo, errors.Error)
+
+//ValidateFunc validates the input of the incoming request
+type ValidateFunc func(RouteDetails) errors.Error
 
 // RegisterValidatedRoutes function registers all the given handlers against the given path and method combo
-// TODO :- paths can also be regexes
+// on the http.DefaultServeMux, panics if any of the path patterns is invalid.
+// Use NewServer to run isolated charon instances instead
 func RegisterValidatedRoutes(handlers map[PathDetail]RouteHandler, respHandler ResponseHandler,
 	logger *logr.Logger) {
 
-	serverHandler := &charonServerHandler{
-		logger:       logger,
-		respHandler:  respHandler,
-		pathHandlers: handlers,
-	}
-	http.HandleFunc("/", serverHandler.ServeRequest)
+	server := NewServer(WithLogger(logger), WithResponseHandler(respHandler), WithRoutes(handlers))
+	http.Handle("/", server)
 }
 
 //HandleRequest handle incoming requests
 func HandleRequest(handler RouteHandler, rDetails *RouteDetails, req *http.Request) ([]byte, errors.Error) {
-	ctx, userInfo, auErr := handler.IsAuthenticated(req.Context(), req.Header)
-	if auErr != nil {
-		return nil, auErr
-	} else {
-		if ctx != nil {
-			req = req.WithContext(ctx)
+	return handleRequest(handler, routeConfig{}, nil, rDetails, req)
+}
+
+//handleRequest handles the incoming request, running the group level hooks of the route before the handler's own.
+//The authentication and validation of the handler run if it implements Authenticator and Validator, the access
+//policy of the route is checked in between
+func handleRequest(handler Handler, config routeConfig, access *AccessPolicy, rDetails *RouteDetails, req *http.Request) ([]byte, errors.Error) {
+	if config.authenticate != nil {
+		var auErr errors.Error
+		if req, auErr = authenticate(config.authenticate, req); auErr != nil {
+			return nil, auErr
 		}
-		if userInfo != nil {
-			req.URL.User = userInfo
+	}
+	if authenticator, ok := handler.(Authenticator); ok {
+		var auErr errors.Error
+		if req, auErr = authenticate(authenticator.IsAuthenticated, req); auErr != nil {
+			return nil, auErr
 		}
 	}
 	rDetails.ctx = req.Context()
+	rDetails.req = req
 
-	authError := handler.IsValidInput(*rDetails)
-	if authError != nil {
-		return nil, authError
+	if azErr := authorize(access, *rDetails); azErr != nil {
+		return nil, azErr
+	}
+	if config.validate != nil {
+		if validErr := config.validate(*rDetails); validErr != nil {
+			return nil, validErr
+		}
+	}
+	if validator, ok := handler.(Validator); ok {
+		if validErr := validator.IsValidInput(*rDetails); validErr != nil {
+			return nil, validErr
+		}
 	}
 
 	resp, err := handler.HandleCall(rDetails)
@@ -208,17 +382,44 @@ func HandleRequest(handler RouteHandler, rDetails *RouteDetails, req *http.Reque
 
 }
 
-//method handles sending response
-func handleResponse(resp http.ResponseWriter, writableResp []byte, err errors.Error, respHandler ResponseHandler) {
+//authenticate runs the authentication, returning the request with the resulting context and userinfo
+func authenticate(authFunc AuthenticateFunc, req *http.Request) (*http.Request, errors.Error) {
+	ctx, userInfo, auErr := authFunc(req.Context(), req.Header)
+	if auErr != nil {
+		return req, auErr
+	}
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+	if userInfo != nil {
+		req.URL.User = userInfo
+	}
+	return req, nil
+}
+
+//method handles sending response, along with the response headers and cookies set on the route details. Errors are
+//encoded with the codec negotiated from the Accept header, responses default to JSON unless the handler
+//set their Content-Type
+func handleResponse(resp http.ResponseWriter, rDetails *RouteDetails, writableResp []byte, err errors.Error, respHandler ResponseHandler) {
+	for key, values := range rDetails.respHeader {
+		resp.Header()[key] = values
+	}
+	if authErr, ok := err.(errors.AuthenticationError); ok && authErr.Challenge != "" {
+		resp.Header().Set("WWW-Authenticate", authErr.Challenge)
+	}
 	if respHandler != nil {
 		respHandler(resp, writableResp, err)
 	} else {
-		resp.Header().Set("Content-Type", "application/json")
 		var jsonStream []byte
 		if err != nil {
-			jsonStream = errors.GetMessageBytes(err)
+			var contentType string
+			contentType, jsonStream = rDetails.encodeError(err)
+			resp.Header().Set("Content-Type", contentType)
 			resp.WriteHeader(err.StatusCode())
 		} else {
+			if resp.Header().Get("Content-Type") == "" {
+				resp.Header().Set("Content-Type", defaultMediaType)
+			}
 			jsonStream = writableResp
 			resp.WriteHeader(http.StatusOK)
 		}
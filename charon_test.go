@@ -0,0 +1,37 @@
+package charon
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/charon/errors"
+)
+
+func TestRecoverPanic(t *testing.T) {
+	panics := map[string]func(){
+		"/string": func() { panic("failed") },
+		"/error":  func() { panic(fmt.Errorf("failed")) },
+		"/runtime": func() {
+			var counts map[string]int
+			counts["x"]++
+		},
+	}
+
+	server := NewServer()
+	for path, fn := range panics {
+		fn := fn
+		server.Add(Route(http.MethodGet, path, func(detail *RouteDetails) ([]byte, errors.Error) {
+			fn()
+			return nil, nil
+		}))
+	}
+	for path := range panics {
+		resp := httptest.NewRecorder()
+		server.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, path, nil))
+		if resp.Code != http.StatusInternalServerError {
+			t.Errorf("%s: expected status %d, got %d", path, http.StatusInternalServerError, resp.Code)
+		}
+	}
+}
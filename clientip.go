@@ -0,0 +1,121 @@
+package charon
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// WithTrustedProxies sets the proxies trusted to report the client address, as CIDR ranges or single IPs,
+// panics if one of them is invalid. Without trusted proxies the client IP is always the remote address
+func WithTrustedProxies(cidrs ...string) Option {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				panic(fmt.Sprintf("charon: invalid trusted proxy %q", cidr))
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(fmt.Sprintf("charon: invalid trusted proxy %q: %s", cidr, err.Error()))
+		}
+		nets = append(nets, ipNet)
+	}
+	return func(server *Server) {
+		server.trustedProxies = append(server.trustedProxies, nets...)
+	}
+}
+
+//ClientIP returns the IP address of the client of the incoming http request, as reported by the trusted
+//proxies in the Forwarded, X-Forwarded-For or X-Real-IP headers, or the remote address otherwise
+func (detail RouteDetails) ClientIP() string {
+	return detail.clientIP
+}
+
+// clientIP resolves the client IP of the request. The hops reported by the proxies are walked from the
+// closest one, the first hop not trusted is the client, as the hops before it may be forged by the client.
+// Forwarded is preferred to X-Forwarded-For, which is preferred to X-Real-IP
+func (server *Server) clientIP(req *http.Request) string {
+	remote := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	if !server.isTrustedProxy(remote) {
+		return remote
+	}
+
+	var hops []string
+	if forwarded := req.Header.Values("Forwarded"); len(forwarded) > 0 {
+		hops = parseForwarded(strings.Join(forwarded, ","))
+	} else if forwardedFor := req.Header.Values("X-Forwarded-For"); len(forwardedFor) > 0 {
+		for _, hop := range strings.Split(strings.Join(forwardedFor, ","), ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	} else if realIP := req.Header.Get("X-Real-IP"); realIP != "" {
+		hops = []string{strings.TrimSpace(realIP)}
+	}
+
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseHop(hops[i])
+		if ip == "" {
+			// unknown or obfuscated hops cannot be verified, the last trusted proxy is reported instead
+			break
+		}
+		client = ip
+		if !server.isTrustedProxy(ip) {
+			break
+		}
+	}
+	return client
+}
+
+// isTrustedProxy checks if the address belongs to one of the trusted proxies
+func (server *Server) isTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range server.trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseForwarded returns the "for" parameters of the RFC 7239 Forwarded header, in order
+func parseForwarded(forwarded string) []string {
+	var hops []string
+	for _, element := range strings.Split(forwarded, ",") {
+		for _, pair := range strings.Split(element, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+			if ok && strings.EqualFold(name, "for") {
+				hops = append(hops, strings.Trim(value, `"`))
+			}
+		}
+	}
+	return hops
+}
+
+// parseHop returns the IP of a hop, stripping the port and brackets, empty if the hop is not an IP
+func parseHop(hop string) string {
+	if host, _, err := net.SplitHostPort(hop); err == nil {
+		hop = host
+	}
+	hop = strings.TrimSuffix(strings.TrimPrefix(hop, "["), "]")
+	ip := net.ParseIP(hop)
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
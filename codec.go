@@ -0,0 +1,289 @@
+package charon
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charon/errors"
+)
+
+// Codec decodes the request bodies and encodes the response values of a media type
+type Codec interface {
+	Decode(data []byte, v interface{}) error
+	Encode(v interface{}) ([]byte, error)
+}
+
+// defaultMediaType the media type of the bodies without a Content-Type, and of the responses by default
+const defaultMediaType = "application/json"
+
+// codecRegistry the codecs of a server keyed by media type
+type codecRegistry map[string]Codec
+
+// newCodecRegistry returns the registry of the built-in codecs, JSON and XML
+func newCodecRegistry() codecRegistry {
+	return codecRegistry{
+		"application/json": jsonCodec{},
+		"application/xml":  xmlCodec{},
+		"text/xml":         xmlCodec{},
+	}
+}
+
+// WithCodec registers the codec for the given media type, replacing the built-in one if any.
+// Codecs for formats outside the standard library, like MessagePack, CBOR or Protobuf, are
+// registered this way, e.g. WithCodec("application/msgpack", msgpackCodec{})
+func WithCodec(mediaType string, codec Codec) Option {
+	return func(server *Server) {
+		server.codecs[strings.ToLower(mediaType)] = codec
+	}
+}
+
+// lookup returns the codec for the given media type, falling back to the codec of its structured
+// syntax suffix, "application/problem+json" is decoded as "application/json"
+func (codecs codecRegistry) lookup(mediaType string) Codec {
+	if codec, ok := codecs[mediaType]; ok {
+		return codec
+	}
+	if idx := strings.LastIndex(mediaType, "+"); idx >= 0 {
+		if codec, ok := codecs["application/"+mediaType[idx+1:]]; ok {
+			return codec
+		}
+	}
+	return nil
+}
+
+// negotiate returns the media type and codec to encode the responses with, as per the Accept header,
+// defaulting to JSON if the header is missing or none of its media types has a codec
+func (codecs codecRegistry) negotiate(accept string) (string, Codec) {
+	type accepted struct {
+		mediaType string
+		q         float64
+	}
+	var candidates []accepted
+	for _, entry := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(entry))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			candidates = append(candidates, accepted{mediaType: mediaType, q: q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+
+	mediaTypes := make([]string, 0, len(codecs))
+	for mediaType := range codecs {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	sort.Strings(mediaTypes)
+
+	for _, candidate := range candidates {
+		switch {
+		case candidate.mediaType == "*/*":
+			return defaultMediaType, codecs[defaultMediaType]
+		case strings.HasSuffix(candidate.mediaType, "/*"):
+			prefix := strings.TrimSuffix(candidate.mediaType, "*")
+			if strings.HasPrefix(defaultMediaType, prefix) {
+				return defaultMediaType, codecs[defaultMediaType]
+			}
+			for _, mediaType := range mediaTypes {
+				if strings.HasPrefix(mediaType, prefix) {
+					return mediaType, codecs[mediaType]
+				}
+			}
+		default:
+			if codec := codecs.lookup(candidate.mediaType); codec != nil {
+				return candidate.mediaType, codec
+			}
+		}
+	}
+	return defaultMediaType, codecs[defaultMediaType]
+}
+
+// Encode encodes the value with the codec negotiated from the Accept header of the incoming http request,
+// JSON by default, and sets the Content-Type of the response accordingly
+func (detail RouteDetails) Encode(v interface{}) ([]byte, errors.Error) {
+	mediaType, codec := detail.respMediaType, detail.respCodec
+	if codec == nil {
+		mediaType, codec = defaultMediaType, jsonCodec{}
+	}
+	data, err := codec.Encode(v)
+	if err != nil {
+		return nil, errors.InternalError{Err: fmt.Sprint("Unable to encode the response as ", mediaType, " : ", err.Error())}
+	}
+	if detail.respHeader != nil {
+		detail.respHeader.Set("Content-Type", mediaType)
+	}
+	return data, nil
+}
+
+// encodeError encodes the error message with the negotiated codec, returning the Content-Type along
+func (detail RouteDetails) encodeError(err errors.Error) (string, []byte) {
+	if detail.respCodec != nil && detail.respMediaType != defaultMediaType {
+		if data, encErr := detail.respCodec.Encode(errors.GetMessage(err)); encErr == nil {
+			return detail.respMediaType, data
+		}
+	}
+	return defaultMediaType, errors.GetMessageBytes(err)
+}
+
+// xmlCodec the built-in codec for XML. Values are handled by encoding/xml, except for
+// map[string]interface{} values, where each element is a key holding its text, the map
+// of its child elements, or a slice of those when repeated. Attributes are keyed "@name",
+// and the text of the elements having attributes or child elements is keyed "#text"
+type xmlCodec struct{}
+
+// xmlTextKey the key of the text of the elements decoded into a map
+const xmlTextKey = "#text"
+
+// Decode decodes the XML document into the value
+func (xmlCodec) Decode(data []byte, v interface{}) error {
+	target, ok := v.(*map[string]interface{})
+	if !ok {
+		return xml.Unmarshal(data, v)
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			value, err := decodeXMLElement(decoder, start)
+			if err != nil {
+				return err
+			}
+			if children, ok := value.(map[string]interface{}); ok {
+				*target = children
+			} else {
+				*target = map[string]interface{}{start.Name.Local: value}
+			}
+			return nil
+		}
+	}
+}
+
+// decodeXMLElement decodes the element into its text, or the map of its attributes, child elements and text
+func decodeXMLElement(decoder *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	children := make(map[string]interface{})
+	for _, attr := range start.Attr {
+		children["@"+attr.Name.Local] = attr.Value
+	}
+	var text strings.Builder
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			value, err := decodeXMLElement(decoder, t)
+			if err != nil {
+				return nil, err
+			}
+			name := t.Name.Local
+			switch existing := children[name].(type) {
+			case nil:
+				children[name] = value
+			case []interface{}:
+				children[name] = append(existing, value)
+			default:
+				children[name] = []interface{}{existing, value}
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			trimmed := strings.TrimSpace(text.String())
+			if len(children) == 0 {
+				return trimmed, nil
+			}
+			if trimmed != "" {
+				children[xmlTextKey] = trimmed
+			}
+			return children, nil
+		}
+	}
+}
+
+// Encode encodes the value as an XML document, maps are encoded under a "response" root element
+func (xmlCodec) Encode(v interface{}) ([]byte, error) {
+	values, ok := v.(map[string]interface{})
+	if !ok {
+		return xml.Marshal(v)
+	}
+	var buf bytes.Buffer
+	encoder := xml.NewEncoder(&buf)
+	if err := encodeXMLElement(encoder, "response", values); err != nil {
+		return nil, err
+	}
+	if err := encoder.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encodeXMLElement encodes the value as an element with the given name
+func encodeXMLElement(encoder *xml.Encoder, name string, value interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if strings.HasPrefix(key, "@") {
+				start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: key[1:]}, Value: fmt.Sprint(v[key])})
+			}
+		}
+		if err := encoder.EncodeToken(start); err != nil {
+			return err
+		}
+		if text, ok := v[xmlTextKey]; ok {
+			if err := encoder.EncodeToken(xml.CharData(fmt.Sprint(text))); err != nil {
+				return err
+			}
+		}
+		for _, key := range keys {
+			if strings.HasPrefix(key, "@") || key == xmlTextKey {
+				continue
+			}
+			if err := encodeXMLElement(encoder, key, v[key]); err != nil {
+				return err
+			}
+		}
+		return encoder.EncodeToken(start.End())
+	case []interface{}:
+		for _, item := range v {
+			if err := encodeXMLElement(encoder, name, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case nil:
+		return encoder.EncodeElement("", start)
+	case string, bool, float64, float32, int, int64, int32, uint, uint64, json.Number:
+		return encoder.EncodeElement(fmt.Sprint(v), start)
+	default:
+		return encoder.EncodeElement(v, start)
+	}
+}
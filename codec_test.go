@@ -0,0 +1,62 @@
+package charon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/charon/errors"
+)
+
+func TestXMLRoundTrip(t *testing.T) {
+	tests := []struct {
+		body    string
+		decoded map[string]interface{}
+		encoded string
+	}{
+		{
+			`<req><a>1</a><b x="y">2</b></req>`,
+			map[string]interface{}{"a": "1", "b": map[string]interface{}{"@x": "y", "#text": "2"}},
+			`<response><a>1</a><b x="y">2</b></response>`,
+		},
+		{
+			`<req><p>Hello <b>x</b> world</p></req>`,
+			map[string]interface{}{"p": map[string]interface{}{"b": "x", "#text": "Hello  world"}},
+			`<response><p>Hello  world<b>x</b></p></response>`,
+		},
+		{
+			`<req id="7"> <item>1</item> <item>2</item> </req>`,
+			map[string]interface{}{"@id": "7", "item": []interface{}{"1", "2"}},
+			`<response id="7"><item>1</item><item>2</item></response>`,
+		},
+		{
+			`<req><b x="y"/><c>a &amp; b</c></req>`,
+			map[string]interface{}{"b": map[string]interface{}{"@x": "y"}, "c": "a & b"},
+			`<response><b x="y"></b><c>a &amp; b</c></response>`,
+		},
+	}
+
+	server := NewServer()
+	server.Add(Route(http.MethodPost, "/echo", func(detail *RouteDetails) ([]byte, errors.Error) {
+		return detail.Encode(detail.Body())
+	}))
+	for _, test := range tests {
+		var decoded map[string]interface{}
+		if err := (xmlCodec{}).Decode([]byte(test.body), &decoded); err != nil {
+			t.Fatalf("%s: %s", test.body, err.Error())
+		}
+		if !jsonEqual(decoded, test.decoded) {
+			t.Errorf("%s: expected %v, got %v", test.body, test.decoded, decoded)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/echo", strings.NewReader(test.body))
+		req.Header.Set("Content-Type", "application/xml")
+		req.Header.Set("Accept", "application/xml")
+		resp := httptest.NewRecorder()
+		server.ServeHTTP(resp, req)
+		if resp.Code != http.StatusOK || resp.Body.String() != test.encoded {
+			t.Errorf("%s: expected %s, got %d %s", test.body, test.encoded, resp.Code, resp.Body.String())
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package charon
+
+import (
+	"net/http"
+)
+
+// CookieDefaults the attributes applied to the cookies queued with RouteDetails.SetCookie
+type CookieDefaults struct {
+	// Path the path of the cookies without one, defaults to "/"
+	Path string
+
+	// Secure, HttpOnly are set on every cookie when true, see RouteDetails.SetCookieWithoutDefaults
+	// for the cookies that must not have them
+	Secure   bool
+	HttpOnly bool
+
+	// SameSite the SameSite mode of the cookies without one, defaults to http.SameSiteLaxMode
+	SameSite http.SameSite
+}
+
+// defaultCookieDefaults the cookie defaults of a server, Secure, HttpOnly and SameSite=Lax cookies for the whole site
+var defaultCookieDefaults = CookieDefaults{Path: "/", Secure: true, HttpOnly: true, SameSite: http.SameSiteLaxMode}
+
+// WithCookieDefaults sets the attributes applied to the cookies queued with RouteDetails.SetCookie,
+// defaults to Secure, HttpOnly, SameSite=Lax cookies with the "/" path
+func WithCookieDefaults(cookieDefaults CookieDefaults) Option {
+	return func(server *Server) {
+		if cookieDefaults.Path == "" {
+			cookieDefaults.Path = "/"
+		}
+		if cookieDefaults.SameSite == 0 {
+			cookieDefaults.SameSite = http.SameSiteLaxMode
+		}
+		server.cookieDefaults = cookieDefaults
+	}
+}
+
+// apply sets the defaults on a copy of the cookie
+func (cookieDefaults CookieDefaults) apply(cookie http.Cookie) *http.Cookie {
+	if cookie.Path == "" {
+		cookie.Path = cookieDefaults.Path
+	}
+	if cookie.SameSite == 0 {
+		cookie.SameSite = cookieDefaults.SameSite
+	}
+	cookie.Secure = cookie.Secure || cookieDefaults.Secure
+	cookie.HttpOnly = cookie.HttpOnly || cookieDefaults.HttpOnly
+	return &cookie
+}
+
+//Cookie returns the named cookie sent with the incoming http request, nil if not present
+func (detail RouteDetails) Cookie(name string) *http.Cookie {
+	if detail.req == nil {
+		return nil
+	}
+	cookie, err := detail.req.Cookie(name)
+	if err != nil {
+		return nil
+	}
+	return cookie
+}
+
+//Cookies returns the cookies sent with the incoming http request
+func (detail RouteDetails) Cookies() []*http.Cookie {
+	if detail.req == nil {
+		return nil
+	}
+	return detail.req.Cookies()
+}
+
+//SetCookie queues the cookie to be sent with the response, the cookie defaults of the server are
+//applied, see WithCookieDefaults. Invalid cookies are dropped, as with http.SetCookie
+func (detail RouteDetails) SetCookie(cookie *http.Cookie) {
+	if cookie == nil {
+		return
+	}
+	detail.addCookie(detail.cookieDefaults.apply(*cookie))
+}
+
+//SetCookieWithoutDefaults queues the cookie to be sent with the response as is, without the cookie
+//defaults of the server, for the cookies that must be readable by scripts or sent over plain http
+func (detail RouteDetails) SetCookieWithoutDefaults(cookie *http.Cookie) {
+	detail.addCookie(cookie)
+}
+
+// addCookie adds the Set-Cookie header of the cookie to the response, invalid cookies are dropped
+func (detail RouteDetails) addCookie(cookie *http.Cookie) {
+	if detail.respHeader == nil || cookie == nil {
+		return
+	}
+	if value := cookie.String(); value != "" {
+		detail.respHeader.Add("Set-Cookie", value)
+	}
+}
@@ -0,0 +1,24 @@
+package charon
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestSetCookie(t *testing.T) {
+	detail := RouteDetails{respHeader: make(http.Header), cookieDefaults: defaultCookieDefaults}
+	detail.SetCookie(&http.Cookie{Name: "session", Value: "s"})
+	detail.SetCookieWithoutDefaults(&http.Cookie{Name: "theme", Value: "dark"})
+	detail.SetCookie(&http.Cookie{Name: "invalid name", Value: "x"})
+
+	expected := []string{"session=s; Path=/; HttpOnly; Secure; SameSite=Lax", "theme=dark"}
+	cookies := detail.respHeader.Values("Set-Cookie")
+	if len(cookies) != len(expected) {
+		t.Fatalf("expected cookies %q, got %q", expected, cookies)
+	}
+	for i := range expected {
+		if cookies[i] != expected[i] {
+			t.Errorf("expected cookie %q, got %q", expected[i], cookies[i])
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package charon
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/charon/errors"
+)
+
+// defaultDecodedBodySize the maximum size of the decoded bodies when no body size limit is set,
+// so that a small compressed body cannot be inflated without bound
+const defaultDecodedBodySize = 10 << 20
+
+// Decompressor returns the reader of the decoded body for a body encoded with a content coding
+type Decompressor func(body io.Reader) (io.ReadCloser, error)
+
+// newDecompressors returns the built-in decompressors, gzip and deflate
+func newDecompressors() map[string]Decompressor {
+	return map[string]Decompressor{
+		"gzip":    gzipDecompressor,
+		"x-gzip":  gzipDecompressor,
+		"deflate": deflateDecompressor,
+	}
+}
+
+// WithDecompressor registers the decompressor for the given content coding, replacing the built-in one if any.
+// Codings outside the standard library, like Brotli, are registered this way, e.g. WithDecompressor("br", brotliReader)
+func WithDecompressor(encoding string, decompressor Decompressor) Option {
+	return func(server *Server) {
+		server.decompressors[strings.ToLower(encoding)] = decompressor
+	}
+}
+
+// decodeBody replaces the body of the request with its decoded body as per the Content-Encoding header,
+// the codings are undone in the reverse order they were applied. The body size limit applies to the
+// decoded body, so compressed bodies cannot get past it. Requests without a body are left as is,
+// returns whether the body was decoded
+func (server *Server) decodeBody(req *http.Request) (bool, errors.Error) {
+	if req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0 {
+		return false, nil
+	}
+
+	encodings := strings.Split(req.Header.Get("Content-Encoding"), ",")
+	body := io.Reader(req.Body)
+	for i := len(encodings) - 1; i >= 0; i-- {
+		encoding := strings.ToLower(strings.TrimSpace(encodings[i]))
+		if encoding == "" || encoding == "identity" {
+			continue
+		}
+		decompressor, ok := server.decompressors[encoding]
+		if !ok {
+			return false, errors.UnsupportedMediaTypeError{Err: fmt.Sprint("Unsupported Content-Encoding ", encoding)}
+		}
+		decoded, err := decompressor(body)
+		if err != nil {
+			return false, errors.InvalidInputError{Err: fmt.Sprint("Unable to decode the ", encoding, " body : ", err.Error()), Mess: "Invalid request body"}
+		}
+		body = decoded
+	}
+
+	req.Body = struct {
+		io.Reader
+		io.Closer
+	}{body, req.Body}
+	req.ContentLength = -1
+	req.Header.Del("Content-Encoding")
+	req.Header.Del("Content-Length")
+	return true, nil
+}
+
+// gzipDecompressor decodes gzip bodies
+func gzipDecompressor(body io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(body)
+}
+
+// deflateDecompressor decodes deflate bodies, zlib wrapped as per the HTTP spec, or raw deflate as some clients send
+func deflateDecompressor(body io.Reader) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(body)
+	header, err := buffered.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(buffered)
+	}
+	return flate.NewReader(buffered), nil
+}
@@ -0,0 +1,61 @@
+package charon
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/charon/errors"
+)
+
+// gzipped returns the gzip encoding of the data
+func gzipped(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeBody(t *testing.T) {
+	server := NewServer()
+	echo := func(detail *RouteDetails) ([]byte, errors.Error) {
+		return []byte(strconv.Itoa(len(detail.RawBody()))), nil
+	}
+	server.Add(Route(http.MethodPost, "/echo", echo), Route(http.MethodGet, "/echo", echo))
+
+	bomb := gzipped(t, make([]byte, defaultDecodedBodySize+1))
+	tests := []struct {
+		method string
+		path   string
+		body   []byte
+		status int
+		resp   string
+	}{
+		{http.MethodPost, "/echo", gzipped(t, []byte(`{"a":1}`)), http.StatusOK, "7"},
+		{http.MethodGet, "/echo", nil, http.StatusOK, "0"},
+		{http.MethodPost, "/echo", []byte{}, http.StatusOK, "0"},
+		{http.MethodPost, "/echo", []byte("not gzip"), http.StatusBadRequest, ""},
+		{http.MethodPost, "/echo", bomb, http.StatusRequestEntityTooLarge, ""},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, test.path, bytes.NewReader(test.body))
+		req.Header.Set("Content-Encoding", "gzip")
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		server.ServeHTTP(resp, req)
+
+		if resp.Code != test.status {
+			t.Errorf("%s %s: expected status %d, got %d %s", test.method, test.path, test.status, resp.Code, resp.Body.String())
+		} else if test.resp != "" && resp.Body.String() != test.resp {
+			t.Errorf("%s %s: expected %q, got %q", test.method, test.path, test.resp, resp.Body.String())
+		}
+	}
+}
@@ -12,10 +12,12 @@ type Error interface {
 	StatusCode() int
 }
 
-// AuthenticationError invalid authentication error
+// AuthenticationError invalid authentication error. Challenge is sent as the WWW-Authenticate header,
+// along with the 401 status code, when set
 type AuthenticationError struct {
-	Mess string
-	Err  string
+	Mess      string
+	Err       string
+	Challenge string
 }
 
 // Error returns the error message for the AuthenticationError
@@ -33,6 +35,9 @@ func (e AuthenticationError) Message() string {
 
 // StatusCode returns the status code to be sent in the response for the AuthenticationError
 func (e AuthenticationError) StatusCode() int {
+	if e.Challenge != "" {
+		return http.StatusUnauthorized
+	}
 	return http.StatusForbidden
 }
 
@@ -60,10 +65,18 @@ func (e AuthorizationError) StatusCode() int {
 	return http.StatusUnauthorized
 }
 
-// InvalidInputError invalid input error
+// InvalidInputError invalid input error. Offset is the byte offset of the problem in the request body, if known
 type InvalidInputError struct {
-	Mess string
-	Err  string
+	Mess   string
+	Err    string
+	Fields []FieldError
+	Offset *int64
+}
+
+// FieldError the reason a single field of the input is invalid
+type FieldError struct {
+	Field  string `json:"field" xml:"field"`
+	Reason string `json:"reason" xml:"reason"`
 }
 
 // Error returns the error message for the InvalidInputError
@@ -108,6 +121,54 @@ func (e InternalError) StatusCode() int {
 	return http.StatusInternalServerError
 }
 
+//NotFoundError the requested resource could not be found
+type NotFoundError struct {
+	Mess string
+	Err  string
+}
+
+// Error returns the error message for the NotFoundError
+func (e NotFoundError) Error() string {
+	return e.Err
+}
+
+// Message returns the error message to be sent with the response for the NotFoundError
+func (e NotFoundError) Message() string {
+	if e.Mess != "" {
+		return e.Mess
+	}
+	return "Not Found"
+}
+
+// StatusCode returns the status code to be sent in the response for the NotFoundError
+func (e NotFoundError) StatusCode() int {
+	return http.StatusNotFound
+}
+
+//PayloadTooLargeError the request body is larger than the allowed limit
+type PayloadTooLargeError struct {
+	Mess string
+	Err  string
+}
+
+// Error returns the error message for the PayloadTooLargeError
+func (e PayloadTooLargeError) Error() string {
+	return e.Err
+}
+
+// Message returns the error message to be sent with the response for the PayloadTooLargeError
+func (e PayloadTooLargeError) Message() string {
+	if e.Mess != "" {
+		return e.Mess
+	}
+	return "Payload Too Large"
+}
+
+// StatusCode returns the status code to be sent in the response for the PayloadTooLargeError
+func (e PayloadTooLargeError) StatusCode() int {
+	return http.StatusRequestEntityTooLarge
+}
+
 //InvalidMethodError the url does not support the given method
 type InvalidMethodError struct {
 	Mess string
@@ -132,6 +193,30 @@ func (e InvalidMethodError) StatusCode() int {
 	return http.StatusMethodNotAllowed
 }
 
+//UnsupportedMediaTypeError the request body is of a media type the server cannot decode
+type UnsupportedMediaTypeError struct {
+	Mess string
+	Err  string
+}
+
+// Error returns the error message for the UnsupportedMediaTypeError
+func (e UnsupportedMediaTypeError) Error() string {
+	return e.Err
+}
+
+// Message returns the error message to be sent with the response for the UnsupportedMediaTypeError
+func (e UnsupportedMediaTypeError) Message() string {
+	if e.Mess != "" {
+		return e.Mess
+	}
+	return "Unsupported Media Type"
+}
+
+// StatusCode returns the status code to be sent in the response for the UnsupportedMediaTypeError
+func (e UnsupportedMediaTypeError) StatusCode() int {
+	return http.StatusUnsupportedMediaType
+}
+
 //CustomStatusError an error with a custom message and status code
 type CustomStatusError struct {
 	Mess   string
@@ -157,11 +242,22 @@ func (e CustomStatusError) StatusCode() int {
 	return e.Status
 }
 
-// struct to hold complete error messages
-func GetMessageBytes(err Error) []byte {
-	vals := make(map[string]string)
+// GetMessage returns the values of the error to be sent with the response
+func GetMessage(err Error) map[string]interface{} {
+	vals := make(map[string]interface{})
 	vals["message"] = err.Message()
 	vals["status"] = "error"
-	js, _ := json.Marshal(vals)
+	if inputErr, ok := err.(InvalidInputError); ok && len(inputErr.Fields) > 0 {
+		vals["fields"] = inputErr.Fields
+	}
+	if inputErr, ok := err.(InvalidInputError); ok && inputErr.Offset != nil {
+		vals["offset"] = *inputErr.Offset
+	}
+	return vals
+}
+
+// struct to hold complete error messages
+func GetMessageBytes(err Error) []byte {
+	js, _ := json.Marshal(GetMessage(err))
 	return js
 }
@@ -0,0 +1,124 @@
+package charon
+
+import (
+	"context"
+	"io/fs"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// routeConfig the group level hooks applied to a route, these run before the hooks of the RouteHandler itself
+type routeConfig struct {
+	authenticate AuthenticateFunc
+	validate     ValidateFunc
+	respHandler  ResponseHandler
+	middlewares  []Middleware
+}
+
+// RouteOption configures a group or a single route. Options given to a route
+// override the ones inherited from its group, the same way options given to
+// a nested group override the ones of its parent
+type RouteOption func(*routeConfig)
+
+// AuthenticateWith sets the authentication run before the IsAuthenticated of the route handlers,
+// a nil function removes the inherited authentication
+func AuthenticateWith(authFunc AuthenticateFunc) RouteOption {
+	return func(config *routeConfig) {
+		config.authenticate = authFunc
+	}
+}
+
+// ValidateWith sets the validation run before the IsValidInput of the route handlers,
+// a nil function removes the inherited validation
+func ValidateWith(validFunc ValidateFunc) RouteOption {
+	return func(config *routeConfig) {
+		config.validate = validFunc
+	}
+}
+
+// RespondWith sets the response handler used in place of the one of the server,
+// a nil handler falls back to the one of the server
+func RespondWith(respHandler ResponseHandler) RouteOption {
+	return func(config *routeConfig) {
+		config.respHandler = respHandler
+	}
+}
+
+// newRouteConfig applies the options over the inherited config
+func newRouteConfig(inherited routeConfig, opts []RouteOption) routeConfig {
+	config := inherited
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
+
+// Group a set of routes registered under a common path prefix, sharing
+// the authentication, validation and response handling of the group
+type Group struct {
+	server *Server
+	prefix string
+	config routeConfig
+}
+
+// Group creates a nested group under the prefix of this group, inheriting its options
+func (group *Group) Group(prefix string, opts ...RouteOption) *Group {
+	return &Group{
+		server: group.server,
+		prefix: joinPaths(group.prefix, prefix),
+		config: newRouteConfig(group.config, opts),
+	}
+}
+
+// Handle registers the handler against the path prefixed with the group prefix,
+// the given options override the ones of the group for this route only
+func (group *Group) Handle(pDetail PathDetail, handler Handler, opts ...RouteOption) {
+	pDetail.PathRegex = joinPaths(group.prefix, pDetail.PathRegex)
+	if err := group.server.handle(&route{detail: pDetail, handler: handler, config: newRouteConfig(group.config, opts)}, false); err != nil {
+		panic(err.Error())
+	}
+}
+
+// HandleHTTP registers a plain http.Handler against the path prefixed with the group prefix. See Server.HandleHTTP
+func (group *Group) HandleHTTP(pDetail PathDetail, handler http.Handler, opts ...RouteOption) {
+	pDetail.PathRegex = joinPaths(group.prefix, pDetail.PathRegex)
+	if err := group.server.handle(&route{detail: pDetail, httpHandler: handler, config: newRouteConfig(group.config, opts)}, false); err != nil {
+		panic(err.Error())
+	}
+}
+
+// Static serves the files of fsys under the prefix of the group joined with the given prefix. See Server.Static
+func (group *Group) Static(prefix string, fsys fs.FS, staticOptions StaticOptions, opts ...RouteOption) {
+	group.HandleHTTP(PathDetail{Method: http.MethodGet, PathRegex: joinPaths(prefix, "/*filepath")},
+		NewStaticHandler(fsys, staticOptions), opts...)
+}
+
+// Replace registers the handler against the path prefixed with the group prefix, replacing
+// the handler already registered for it, if any. See Server.Replace
+func (group *Group) Replace(pDetail PathDetail, handler Handler, opts ...RouteOption) error {
+	pDetail.PathRegex = joinPaths(group.prefix, pDetail.PathRegex)
+	return group.server.handle(&route{detail: pDetail, handler: handler, config: newRouteConfig(group.config, opts)}, true)
+}
+
+// Remove unregisters the handler registered against the path prefixed with the group prefix. See Server.Remove
+func (group *Group) Remove(ctx context.Context, pDetail PathDetail) error {
+	pDetail.PathRegex = joinPaths(group.prefix, pDetail.PathRegex)
+	return group.server.Remove(ctx, pDetail)
+}
+
+// joinPaths joins the prefix with the given path pattern, a regular expression pattern
+// is prefixed with the quoted prefix
+func joinPaths(prefix, pattern string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if strings.HasPrefix(pattern, "^") {
+		return "^" + regexp.QuoteMeta(prefix) + pattern[1:]
+	}
+	if pattern == "" {
+		return prefix
+	}
+	if !strings.HasPrefix(pattern, "/") {
+		pattern = "/" + pattern
+	}
+	return prefix + pattern
+}
@@ -0,0 +1,71 @@
+package charon
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/charon/errors"
+)
+
+// Handler handles an incoming request, returning the response to be written or the error to be sent.
+// The handlers registered with a server may implement Authenticator and Validator as well, those run
+// before HandleCall when implemented
+type Handler interface {
+	HandleCall(*RouteDetails) ([]byte, errors.Error)
+}
+
+// Authenticator authenticates the incoming requests of a route, see RouteHandler
+type Authenticator interface {
+	IsAuthenticated(context.Context, http.Header) (context.Context, *url.Userinfo, errors.Error)
+}
+
+// Validator validates the input of the incoming requests of a route, see RouteHandler
+type Validator interface {
+	IsValidInput(RouteDetails) errors.Error
+}
+
+// HandlerFunc adapts a function to a Handler
+type HandlerFunc func(*RouteDetails) ([]byte, errors.Error)
+
+// HandleCall calls the function
+func (fn HandlerFunc) HandleCall(rDetails *RouteDetails) ([]byte, errors.Error) {
+	return fn(rDetails)
+}
+
+// IsAuthenticated calls the function, so that an AuthenticateFunc is an Authenticator
+func (fn AuthenticateFunc) IsAuthenticated(ctx context.Context, header http.Header) (context.Context, *url.Userinfo, errors.Error) {
+	return fn(ctx, header)
+}
+
+// IsValidInput calls the function, so that a ValidateFunc is a Validator
+func (fn ValidateFunc) IsValidInput(rDetails RouteDetails) errors.Error {
+	return fn(rDetails)
+}
+
+// Endpoint a handler along with the path and method combo it is to be registered against, see Route
+type Endpoint struct {
+	PathDetail PathDetail
+	Handler    Handler
+	Options    []RouteOption
+}
+
+// Route returns the endpoint serving the path and method combo with the function, to be registered with
+// Server.Add or Group.Add, e.g. server.Add(charon.Route(http.MethodGet, "/health", health))
+func Route(method, path string, fn HandlerFunc, opts ...RouteOption) Endpoint {
+	return Endpoint{PathDetail: PathDetail{Method: method, PathRegex: path}, Handler: fn, Options: opts}
+}
+
+// Add registers the endpoints, panics the same way Handle does
+func (server *Server) Add(endpoints ...Endpoint) {
+	for _, endpoint := range endpoints {
+		server.Handle(endpoint.PathDetail, endpoint.Handler, endpoint.Options...)
+	}
+}
+
+// Add registers the endpoints under the prefix of the group, panics the same way Handle does
+func (group *Group) Add(endpoints ...Endpoint) {
+	for _, endpoint := range endpoints {
+		group.Handle(endpoint.PathDetail, endpoint.Handler, endpoint.Options...)
+	}
+}
@@ -0,0 +1,199 @@
+package charon
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// hostPattern is the compiled form of PathDetail.Host.
+//
+// A host pattern is made of "." separated labels where each label is a literal ("api"),
+// or a named label ("{tenant}") capturing a single label of the host. The first label
+// can also be a wildcard ("*") capturing one or more leading labels of the host
+// under the "*" name. Hosts are matched case insensitively and without port
+type hostPattern struct {
+	raw      string
+	labels   []string
+	wildcard bool
+	literals int
+}
+
+// compileHostPattern parses and compiles the given host pattern
+func compileHostPattern(pattern string) (*hostPattern, error) {
+	compiled := &hostPattern{raw: pattern}
+	for i, label := range strings.Split(strings.ToLower(pattern), ".") {
+		switch {
+		case label == "*":
+			if i != 0 {
+				return nil, fmt.Errorf("charon: invalid host pattern %q: wildcard must be the first label", pattern)
+			}
+			compiled.wildcard = true
+			continue
+		case strings.HasPrefix(label, "{") && strings.HasSuffix(label, "}"):
+			if !isValidParamName(label[1 : len(label)-1]) {
+				return nil, fmt.Errorf("charon: invalid host pattern %q: invalid label name %q", pattern, label)
+			}
+		case label == "" || strings.ContainsAny(label, "{}*"):
+			return nil, fmt.Errorf("charon: invalid host pattern %q: invalid label %q", pattern, label)
+		default:
+			compiled.literals++
+		}
+		compiled.labels = append(compiled.labels, label)
+	}
+	return compiled, nil
+}
+
+// isLiteral checks if the pattern matches a single host
+func (pattern *hostPattern) isLiteral() bool {
+	return !pattern.wildcard && pattern.literals == len(pattern.labels)
+}
+
+// match matches the given lower cased host, without port, against the pattern, returning the captured labels
+func (pattern *hostPattern) match(host string) (map[string]string, bool) {
+	hostLabels := strings.Split(host, ".")
+	leading := len(hostLabels) - len(pattern.labels)
+	if leading < 0 || (leading > 0) != pattern.wildcard {
+		return nil, false
+	}
+
+	var params map[string]string
+	for i, label := range pattern.labels {
+		hostLabel := hostLabels[leading+i]
+		if strings.HasPrefix(label, "{") {
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[label[1:len(label)-1]] = hostLabel
+		} else if label != hostLabel {
+			return nil, false
+		}
+	}
+	if pattern.wildcard {
+		if params == nil {
+			params = make(map[string]string)
+		}
+		params["*"] = strings.Join(hostLabels[:leading], ".")
+	}
+	return params, true
+}
+
+// routeTable the routers of all the hosts served, a snapshot that is never modified once built
+type routeTable struct {
+	exact      map[string]*router
+	patterns   []*hostRouter
+	anyHost    *router
+	ignoreCase bool
+}
+
+// hostRouter the router of the routes registered against a host pattern
+type hostRouter struct {
+	pattern *hostPattern
+	router  *router
+}
+
+// newRouteTable builds the route table for the given routes
+func newRouteTable(routes []*route, ignoreCase bool) (*routeTable, error) {
+	table := &routeTable{exact: make(map[string]*router), anyHost: newRouter(ignoreCase), ignoreCase: ignoreCase}
+	for _, rt := range routes {
+		if err := table.routerFor(rt.host).add(rt); err != nil {
+			return nil, err
+		}
+	}
+	// more specific host patterns first, in the order they were added otherwise
+	sort.SliceStable(table.patterns, func(i, j int) bool {
+		a, b := table.patterns[i].pattern, table.patterns[j].pattern
+		if a.wildcard != b.wildcard {
+			return !a.wildcard
+		}
+		return a.literals > b.literals
+	})
+	return table, nil
+}
+
+// routerFor returns the router for the given host pattern, creating it if needed
+func (table *routeTable) routerFor(pattern *hostPattern) *router {
+	if pattern == nil {
+		return table.anyHost
+	}
+	if pattern.isLiteral() {
+		host := strings.Join(pattern.labels, ".")
+		if _, ok := table.exact[host]; !ok {
+			table.exact[host] = newRouter(table.ignoreCase)
+		}
+		return table.exact[host]
+	}
+	for _, entry := range table.patterns {
+		if entry.pattern.raw == pattern.raw {
+			return entry.router
+		}
+	}
+	entry := &hostRouter{pattern: pattern, router: newRouter(table.ignoreCase)}
+	table.patterns = append(table.patterns, entry)
+	return entry.router
+}
+
+// hostMatch the routes a host level has for the path, along with the captured host labels and path params
+type hostMatch struct {
+	routes     methodRoutes
+	hostParams map[string]string
+	params     map[string]string
+}
+
+// lookup finds the routes registered against the given host and path, along with the captured host labels
+// and path params. Exact hosts are tried first, then host patterns and finally the routes registered for
+// any host, the first one having a route serving the method is used. If none has, the routes of all of
+// them are returned merged, so that the methods allowed for the path are the ones of every host level
+func (table *routeTable) lookup(method, host, path string) (methodRoutes, map[string]string, map[string]string) {
+	host = normalizeHost(host)
+	var matches []hostMatch
+	if hostRouter, ok := table.exact[host]; ok {
+		if routes, params := hostRouter.lookup(path); routes != nil {
+			if routes.serves(method) {
+				return routes, nil, params
+			}
+			matches = append(matches, hostMatch{routes: routes, params: params})
+		}
+	}
+	for _, entry := range table.patterns {
+		hostParams, ok := entry.pattern.match(host)
+		if !ok {
+			continue
+		}
+		if routes, params := entry.router.lookup(path); routes != nil {
+			if routes.serves(method) {
+				return routes, hostParams, params
+			}
+			matches = append(matches, hostMatch{routes: routes, hostParams: hostParams, params: params})
+		}
+	}
+	if routes, params := table.anyHost.lookup(path); routes != nil {
+		if routes.serves(method) {
+			return routes, nil, params
+		}
+		matches = append(matches, hostMatch{routes: routes, params: params})
+	}
+
+	if len(matches) == 0 {
+		return nil, nil, nil
+	}
+	if len(matches) == 1 {
+		return matches[0].routes, matches[0].hostParams, matches[0].params
+	}
+	merged := make(methodRoutes)
+	for i := len(matches) - 1; i >= 0; i-- {
+		for m, rt := range matches[i].routes {
+			merged[m] = rt
+		}
+	}
+	return merged, matches[0].hostParams, matches[0].params
+}
+
+// normalizeHost lower cases the host and strips the port, if any
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
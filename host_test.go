@@ -0,0 +1,55 @@
+package charon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/charon/errors"
+)
+
+func TestHostLevelsFallThrough(t *testing.T) {
+	server := NewServer()
+	answer := func(out string) HandlerFunc {
+		return func(detail *RouteDetails) ([]byte, errors.Error) {
+			return []byte(out + detail.HostParam("tenant")), nil
+		}
+	}
+	server.Handle(PathDetail{Method: http.MethodPost, PathRegex: "/health", Host: "api.example.com"}, answer("exact"))
+	server.Handle(PathDetail{Method: http.MethodPut, PathRegex: "/health", Host: "{tenant}.example.com"}, answer("pattern "))
+	server.Handle(PathDetail{Method: http.MethodGet, PathRegex: "/health"}, answer("any"))
+
+	tests := []struct {
+		method string
+		host   string
+		status int
+		body   string
+		allow  string
+	}{
+		{http.MethodGet, "api.example.com", http.StatusOK, "any", ""},
+		{http.MethodHead, "api.example.com", http.StatusOK, "", ""},
+		{http.MethodPost, "api.example.com", http.StatusOK, "exact", ""},
+		{http.MethodPut, "api.example.com", http.StatusOK, "pattern api", ""},
+		{http.MethodPut, "other.example.com", http.StatusOK, "pattern other", ""},
+		{http.MethodPost, "other.example.com", http.StatusMethodNotAllowed, "", "GET, HEAD, OPTIONS, PUT"},
+		{http.MethodDelete, "api.example.com", http.StatusMethodNotAllowed, "", "GET, HEAD, OPTIONS, POST, PUT"},
+		{http.MethodOptions, "api.example.com", http.StatusNoContent, "", "GET, HEAD, OPTIONS, POST, PUT"},
+		{http.MethodDelete, "example.org", http.StatusMethodNotAllowed, "", "GET, HEAD, OPTIONS"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(test.method, "/health", nil)
+		req.Host = test.host
+		resp := httptest.NewRecorder()
+		server.ServeHTTP(resp, req)
+
+		if resp.Code != test.status {
+			t.Errorf("%s %s: expected status %d, got %d", test.method, test.host, test.status, resp.Code)
+		}
+		if test.body != "" && resp.Body.String() != test.body {
+			t.Errorf("%s %s: expected %q, got %q", test.method, test.host, test.body, resp.Body.String())
+		}
+		if allow := resp.Header().Get("Allow"); allow != test.allow {
+			t.Errorf("%s %s: expected Allow %q, got %q", test.method, test.host, test.allow, allow)
+		}
+	}
+}
@@ -0,0 +1,306 @@
+package charon
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/charon/errors"
+)
+
+// JSONOptions configures the decoding of JSON bodies by the built-in JSON codec
+type JSONOptions struct {
+	// Strict rejects the bodies holding duplicate keys or data after the JSON value,
+	// and Bind rejects the keys that match no field of the struct
+	Strict bool
+
+	// UseNumber decodes the numbers as json.Number rather than float64, preserving their precision
+	UseNumber bool
+}
+
+// WithJSONOptions configures the built-in JSON codec, replacing the codec registered for "application/json"
+func WithJSONOptions(jsonOptions JSONOptions) Option {
+	return func(server *Server) {
+		server.codecs[defaultMediaType] = jsonCodec{options: jsonOptions}
+	}
+}
+
+// JSONError the reason a JSON body could not be decoded, along with the byte offset and the path of the problem.
+// The path is written as the invalid fields of the errors.InvalidInputError, like "items[1].name", empty for the
+// root value. Offset is -1 if unknown
+type JSONError struct {
+	Offset int64
+	Path   string
+	Reason string
+}
+
+// Error returns the description of the JSONError
+func (e *JSONError) Error() string {
+	var b strings.Builder
+	b.WriteString("invalid JSON")
+	if e.Offset >= 0 {
+		fmt.Fprint(&b, " at offset ", e.Offset)
+	}
+	if e.Path != "" {
+		b.WriteString(", path " + e.Path)
+	}
+	b.WriteString(" : " + e.Reason)
+	return b.String()
+}
+
+// decodeError returns the InvalidInputError for a body that could not be decoded, holding the offset
+// and the invalid field of JSONErrors
+func decodeError(err error) errors.Error {
+	invalid := errors.InvalidInputError{Err: err.Error(), Mess: "Invalid request body"}
+	var jsonErr *JSONError
+	if !stderrors.As(err, &jsonErr) {
+		return invalid
+	}
+	if jsonErr.Offset >= 0 {
+		offset := jsonErr.Offset
+		invalid.Offset = &offset
+	}
+	if jsonErr.Path != "" {
+		invalid.Fields = []errors.FieldError{{Field: jsonErr.Path, Reason: jsonErr.Reason}}
+	}
+	return invalid
+}
+
+// jsonCodec the built-in codec for JSON
+type jsonCodec struct {
+	options JSONOptions
+}
+
+// Decode decodes the first JSON value of the data, the whole data in strict mode
+func (codec jsonCodec) Decode(data []byte, v interface{}) error {
+	if codec.options.Strict {
+		if err := scanJSON(data, reflect.TypeOf(v), true); err != nil {
+			return err
+		}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if codec.options.UseNumber {
+		decoder.UseNumber()
+	}
+	if codec.options.Strict {
+		decoder.DisallowUnknownFields()
+	}
+	if err := decoder.Decode(v); err != nil {
+		return toJSONError(data, err)
+	}
+	return nil
+}
+
+// Encode encodes the value as JSON
+func (jsonCodec) Encode(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// toJSONError converts the error of encoding/json into a JSONError
+func toJSONError(data []byte, err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if stderrors.As(err, &typeErr) {
+		return &JSONError{Offset: typeErr.Offset, Path: fieldPath(typeErr.Field), Reason: fmt.Sprint("cannot decode ", typeErr.Value, " into ", typeErr.Type)}
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return &JSONError{Offset: int64(len(data)), Reason: "unexpected end of JSON input"}
+	}
+	var syntaxErr *json.SyntaxError
+	if stderrors.As(err, &syntaxErr) {
+		// the syntax error is located by scanning the data, which reports its path as well
+		if scanErr := scanJSON(data, nil, false); scanErr != nil {
+			return scanErr
+		}
+		return &JSONError{Offset: syntaxErr.Offset, Reason: syntaxErr.Error()}
+	}
+	return &JSONError{Offset: -1, Reason: err.Error()}
+}
+
+// fieldPath rewrites the dotted field of an UnmarshalTypeError, like "items.1.name", as "items[1].name"
+func fieldPath(field string) string {
+	var path string
+	for _, part := range strings.Split(field, ".") {
+		if _, err := strconv.Atoi(part); err == nil && path != "" {
+			path += "[" + part + "]"
+		} else {
+			path = joinJSONPath(path, part)
+		}
+	}
+	return path
+}
+
+// jsonFrame an object or array being scanned
+type jsonFrame struct {
+	object    bool
+	typ       reflect.Type // the type the container is decoded into, nil if not checked
+	keys      map[string]bool
+	key       string
+	expectKey bool
+	index     int
+}
+
+// scanJSON checks the syntax of the JSON value, reporting the offset and path of the first problem. In strict
+// mode the duplicate keys, the keys matching no field of the struct types of typ and the trailing data are reported
+func scanJSON(data []byte, typ reflect.Type, strict bool) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var stack []*jsonFrame
+	for {
+		offset := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return &JSONError{Offset: int64(len(data)), Path: jsonPath(stack), Reason: "unexpected end of JSON input"}
+			}
+			var syntaxErr *json.SyntaxError
+			if stderrors.As(err, &syntaxErr) {
+				offset = syntaxErr.Offset
+			}
+			return &JSONError{Offset: offset, Path: jsonPath(stack), Reason: strings.TrimPrefix(err.Error(), "json: ")}
+		}
+
+		// skipping the whitespace and separators, so that the offset is the one of the token
+		offset += int64(len(data[offset:]) - len(bytes.TrimLeft(data[offset:], " \t\r\n,:")))
+
+		if len(stack) > 0 {
+			top := stack[len(stack)-1]
+			if key, ok := token.(string); ok && top.object && top.expectKey {
+				if strict && top.keys[key] {
+					return &JSONError{Offset: offset, Path: joinJSONPath(jsonPath(stack), key), Reason: "duplicate key"}
+				}
+				if strict && top.typ != nil && top.typ.Kind() == reflect.Struct {
+					if _, found := jsonFieldType(top.typ, key); !found {
+						return &JSONError{Offset: offset, Path: joinJSONPath(jsonPath(stack), key), Reason: "unknown field"}
+					}
+				}
+				top.keys[key], top.key, top.expectKey = true, key, false
+				continue
+			}
+		}
+
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			stack = append(stack, &jsonFrame{
+				object:    token == json.Delim('{'),
+				typ:       jsonChildType(stack, typ),
+				keys:      make(map[string]bool),
+				expectKey: token == json.Delim('{'),
+			})
+			continue
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+		}
+
+		// a value is complete
+		if len(stack) == 0 {
+			if !strict {
+				return nil
+			}
+			end := decoder.InputOffset()
+			if rest := bytes.TrimSpace(data[end:]); len(rest) > 0 {
+				return &JSONError{Offset: int64(len(data) - len(rest)), Reason: "unexpected data after the JSON value"}
+			}
+			return nil
+		}
+		top := stack[len(stack)-1]
+		if top.object {
+			top.expectKey = true
+		} else {
+			top.index++
+		}
+	}
+}
+
+// jsonPath returns the path of the value being scanned
+func jsonPath(stack []*jsonFrame) string {
+	var b strings.Builder
+	for _, frame := range stack {
+		if frame.object {
+			if frame.expectKey {
+				break
+			}
+			path := joinJSONPath(b.String(), frame.key)
+			b.Reset()
+			b.WriteString(path)
+		} else {
+			fmt.Fprint(&b, "[", frame.index, "]")
+		}
+	}
+	return b.String()
+}
+
+// joinJSONPath appends the key to the path
+func joinJSONPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// jsonChildType returns the type the container starting at the current position is decoded into,
+// nil if it is not checked
+func jsonChildType(stack []*jsonFrame, root reflect.Type) reflect.Type {
+	if len(stack) == 0 {
+		return derefType(root)
+	}
+	top := stack[len(stack)-1]
+	if top.typ == nil {
+		return nil
+	}
+	switch top.typ.Kind() {
+	case reflect.Struct:
+		fieldType, _ := jsonFieldType(top.typ, top.key)
+		return derefType(fieldType)
+	case reflect.Map, reflect.Slice, reflect.Array:
+		return derefType(top.typ.Elem())
+	}
+	return nil
+}
+
+// jsonFieldType returns the type of the struct field the key is decoded into, matched case
+// insensitively as encoding/json does, and whether there is such a field
+func jsonFieldType(typ reflect.Type, key string) (reflect.Type, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		structField := typ.Field(i)
+		if structField.Anonymous && structField.Tag.Get("json") == "" {
+			if embedded := derefType(structField.Type); embedded != nil && embedded.Kind() == reflect.Struct {
+				if fieldType, found := jsonFieldType(embedded, key); found {
+					return fieldType, true
+				}
+				continue
+			}
+		}
+		if structField.PkgPath != "" {
+			continue
+		}
+		if name := fieldName(structField); name != "" && strings.EqualFold(name, key) {
+			return structField.Type, true
+		}
+	}
+	return nil, false
+}
+
+// derefType returns the type behind the pointers, nil if the type decodes itself and is not checked
+func derefType(typ reflect.Type) reflect.Type {
+	unmarshaler := reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshaler := reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	for typ != nil {
+		if typ.Implements(unmarshaler) || reflect.PtrTo(typ).Implements(unmarshaler) ||
+			typ.Implements(textUnmarshaler) || reflect.PtrTo(typ).Implements(textUnmarshaler) {
+			return nil
+		}
+		if typ.Kind() != reflect.Ptr {
+			return typ
+		}
+		typ = typ.Elem()
+	}
+	return nil
+}
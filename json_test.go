@@ -0,0 +1,112 @@
+package charon
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/charon/errors"
+)
+
+func TestDecodeErrorResponse(t *testing.T) {
+	server := NewServer(WithJSONOptions(JSONOptions{Strict: true}))
+	server.Add(Route(http.MethodPost, "/items", func(detail *RouteDetails) ([]byte, errors.Error) {
+		return []byte("ok"), nil
+	}))
+
+	tests := []struct {
+		body   string
+		offset float64
+		fields []interface{}
+	}{
+		{`{"a":1,`, 7, nil},
+		{`{"a":1} {"b":2}`, 8, nil},
+		{`{"a":{"b":1,"b":2}}`, 12, []interface{}{map[string]interface{}{"field": "a.b", "reason": "duplicate key"}}},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(test.body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		server.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status %d, got %d", test.body, http.StatusBadRequest, resp.Code)
+		}
+		var message map[string]interface{}
+		if err := json.Unmarshal(resp.Body.Bytes(), &message); err != nil {
+			t.Fatalf("%s: invalid response %s", test.body, resp.Body.String())
+		}
+		if offset, ok := message["offset"]; !ok || offset != test.offset {
+			t.Errorf("%s: expected offset %v, got %s", test.body, test.offset, resp.Body.String())
+		}
+		if fields, _ := message["fields"].([]interface{}); !jsonEqual(fields, test.fields) {
+			t.Errorf("%s: expected fields %v, got %s", test.body, test.fields, resp.Body.String())
+		}
+	}
+}
+
+// jsonEqual checks if the decoded JSON values are the same
+func jsonEqual(a, b interface{}) bool {
+	aData, _ := json.Marshal(a)
+	bData, _ := json.Marshal(b)
+	return string(aData) == string(bData)
+}
+
+// scanTarget the struct the bodies of the scanJSON tests are checked against
+type scanTarget struct {
+	Name  string `json:"name"`
+	Items []struct {
+		City string `json:"city"`
+	} `json:"items"`
+	Meta map[string]interface{} `json:"meta"`
+	Raw  json.RawMessage        `json:"raw"`
+}
+
+func TestScanJSON(t *testing.T) {
+	tests := []struct {
+		data   string
+		strict bool
+		err    *JSONError
+	}{
+		{`{"name":"a","items":[{"city":"x"}],"meta":{"k":1},"raw":{"any":1}}`, true, nil},
+		{`{"NAME":"a"}`, true, nil},
+		{"{\"name\":\"a\"} \n", true, nil},
+
+		// duplicate keys
+		{`{"items": [{"city": "x"}, {"city": "y" , "city": "z"}]}`, true, &JSONError{41, "items[1].city", "duplicate key"}},
+		{`{"meta":{"a":1,"a":2}}`, true, &JSONError{15, "meta.a", "duplicate key"}},
+		{`{"name":"a","name":"b"}`, false, nil},
+
+		// unknown fields, only checked against structs
+		{`{"name":"a","nmae":"b"}`, true, &JSONError{12, "nmae", "unknown field"}},
+		{`{"items":[{"town":"x"}]}`, true, &JSONError{11, "items[0].town", "unknown field"}},
+		{`{"meta":{"anything":{"deep":1}}}`, true, nil},
+		{`{"raw":{"anything":1}}`, true, nil},
+
+		// trailing data
+		{`{"name":"a"} x`, true, &JSONError{13, "", "unexpected data after the JSON value"}},
+		{`{"name":"a"}{"name":"b"}`, true, &JSONError{12, "", "unexpected data after the JSON value"}},
+		{`{"name":"a"} x`, false, nil},
+
+		// syntax errors
+		{`{"items":[{"city":"x"}`, true, &JSONError{22, "items[1]", "unexpected end of JSON input"}},
+		{`[1,2,`, false, &JSONError{5, "[2]", "unexpected end of JSON input"}},
+		{`{"items":[{"city":}]}`, false, &JSONError{19, "items[0].city", "missing value after object key"}},
+	}
+	for _, test := range tests {
+		err := scanJSON([]byte(test.data), reflect.TypeOf(&scanTarget{}), test.strict)
+		if test.err == nil {
+			if err != nil {
+				t.Errorf("%s: expected no error, got %s", test.data, err.Error())
+			}
+			continue
+		}
+		jsonErr, ok := err.(*JSONError)
+		if !ok || *jsonErr != *test.err {
+			t.Errorf("%s: expected %+v, got %#v", test.data, *test.err, err)
+		}
+	}
+}
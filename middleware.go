@@ -0,0 +1,45 @@
+package charon
+
+import (
+	"context"
+)
+
+// Middleware wraps the handling of the requests. The next Handler runs the rest of the chain, down to
+// the authentication, validation and HandleCall of the route. A middleware can return an errors.Error
+// without calling next, change the context with RouteDetails.SetContext, change the request and response
+// headers, and inspect or rewrite the response before it is written.
+//
+// Middlewares wrap the RouteHandlers only, not the http.Handlers registered with HandleHTTP or Static
+type Middleware func(next Handler) Handler
+
+// WithMiddleware adds the middlewares run for every route of the server, before the ones of the groups and routes
+func WithMiddleware(middlewares ...Middleware) Option {
+	return func(server *Server) {
+		server.middlewares = append(server.middlewares, middlewares...)
+	}
+}
+
+// WrapWith adds the middlewares run for the routes of a group or a single route, after the inherited ones
+func WrapWith(middlewares ...Middleware) RouteOption {
+	return func(config *routeConfig) {
+		config.middlewares = append(append([]Middleware(nil), config.middlewares...), middlewares...)
+	}
+}
+
+// chain wraps the handler with the middlewares, the first middleware being the outermost
+func chain(handler Handler, middlewares ...[]Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		for j := len(middlewares[i]) - 1; j >= 0; j-- {
+			handler = middlewares[i][j](handler)
+		}
+	}
+	return handler
+}
+
+//SetContext replaces the context of the incoming http request, for the rest of the handling
+func (detail *RouteDetails) SetContext(ctx context.Context) {
+	detail.ctx = ctx
+	if detail.req != nil {
+		detail.req = detail.req.WithContext(ctx)
+	}
+}
@@ -0,0 +1,146 @@
+package charon
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// TrailingSlashPolicy how a request path differing from a registered path only by a trailing slash is handled
+type TrailingSlashPolicy int
+
+const (
+	// TrailingSlashStrict paths with and without a trailing slash are different routes
+	TrailingSlashStrict TrailingSlashPolicy = iota
+
+	// TrailingSlashRedirect the request is redirected to the registered variant of the path
+	TrailingSlashRedirect
+
+	// TrailingSlashMatch the request is served by the registered variant of the path
+	TrailingSlashMatch
+)
+
+// PathOptions configures how request paths are cleaned before being matched against the routes.
+// Redirects use 301 Moved Permanently for GET and HEAD requests and 308 Permanent Redirect
+// otherwise, so that the method and body of the request are preserved
+type PathOptions struct {
+	// CollapseSlashes replaces repeated slashes with a single one, "//users" becomes "/users"
+	CollapseSlashes bool
+
+	// ResolveDotSegments resolves the "." and ".." segments, "/users/../users" becomes "/users"
+	ResolveDotSegments bool
+
+	// IgnoreCase matches the static segments of the path templates case insensitively
+	IgnoreCase bool
+
+	// RedirectCleaned redirects to the cleaned path instead of serving it
+	RedirectCleaned bool
+
+	// TrailingSlash the policy for paths differing from a route only by a trailing slash
+	TrailingSlash TrailingSlashPolicy
+}
+
+// WithPathOptions sets how the request paths are cleaned, by default paths are matched as is
+func WithPathOptions(pathOptions PathOptions) Option {
+	return func(server *Server) {
+		server.pathOptions = pathOptions
+	}
+}
+
+// clean cleans the path as per the options
+func (pathOptions PathOptions) clean(path string) string {
+	if !pathOptions.CollapseSlashes && !pathOptions.ResolveDotSegments {
+		return path
+	}
+	if !strings.Contains(path, "//") && !strings.Contains(path, "/.") {
+		return path
+	}
+
+	segments := strings.Split(path, "/")
+	cleaned := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch {
+		case segment == "" && pathOptions.CollapseSlashes && i > 0 && !last:
+			continue
+		case segment == "." && pathOptions.ResolveDotSegments:
+			if last {
+				cleaned = append(cleaned, "")
+			}
+			continue
+		case segment == ".." && pathOptions.ResolveDotSegments:
+			if len(cleaned) > 1 {
+				cleaned = cleaned[:len(cleaned)-1]
+			}
+			if last {
+				cleaned = append(cleaned, "")
+			}
+			continue
+		}
+		cleaned = append(cleaned, segment)
+	}
+
+	return strings.Join(cleaned, "/")
+}
+
+// toggleTrailingSlash adds the trailing slash to the path, or removes it if present
+func toggleTrailingSlash(path string) string {
+	if path == "/" {
+		return path
+	}
+	if strings.HasSuffix(path, "/") {
+		return path[:len(path)-1]
+	}
+	return path + "/"
+}
+
+// match finds the route for the request the same way lookup does, after cleaning the path and applying
+// the trailing slash policy. Returns the path the routes were found for, and whether the request must be
+// redirected to it
+func (server *Server) match(method, host, path string) (routes methodRoutes, rt *route,
+	hostParams map[string]string, params map[string]string, routePath string, redirect bool) {
+
+	routePath = server.pathOptions.clean(path)
+	redirect = routePath != path && server.pathOptions.RedirectCleaned
+
+	routes, rt, hostParams, params = server.lookup(method, host, routePath)
+	if routes == nil && server.pathOptions.TrailingSlash != TrailingSlashStrict {
+		if toggled := toggleTrailingSlash(routePath); toggled != routePath {
+			if tRoutes, tRoute, tHostParams, tParams := server.lookup(method, host, toggled); tRoutes != nil {
+				routes, rt, hostParams, params = tRoutes, tRoute, tHostParams, tParams
+				routePath = toggled
+				redirect = redirect || server.pathOptions.TrailingSlash == TrailingSlashRedirect
+			}
+		}
+	}
+	return routes, rt, hostParams, params, routePath, redirect && routes != nil
+}
+
+// redirectTo redirects the request to the given path, preserving the query and the method. The path is
+// prefixed with the one stripped before the request reached the server, if mounted with http.StripPrefix
+func redirectTo(resp http.ResponseWriter, req *http.Request, path string) {
+	status := http.StatusPermanentRedirect
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		status = http.StatusMovedPermanently
+	}
+	// clients take a path starting with "//" or "/\" for a host, the target keeps a single leading slash
+	target := mountPrefix(req) + path
+	if strings.HasPrefix(target, "/") {
+		target = "/" + strings.TrimLeft(target, `/\`)
+	}
+	target = (&url.URL{Path: target}).EscapedPath()
+	if req.URL.RawQuery != "" {
+		target += "?" + req.URL.RawQuery
+	}
+	http.Redirect(resp, req, target, status)
+}
+
+// mountPrefix returns the prefix stripped from the path of the request before it reached the server,
+// as by http.StripPrefix, empty if none
+func mountPrefix(req *http.Request) string {
+	requestURI, err := url.ParseRequestURI(req.RequestURI)
+	if err != nil || !strings.HasSuffix(requestURI.Path, req.URL.Path) {
+		return ""
+	}
+	return requestURI.Path[:len(requestURI.Path)-len(req.URL.Path)]
+}
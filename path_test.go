@@ -0,0 +1,65 @@
+package charon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestRedirectCleanedStaysOnHost(t *testing.T) {
+	server := NewServer(WithPathOptions(PathOptions{ResolveDotSegments: true, RedirectCleaned: true}))
+	server.Static("/", fstest.MapFS{"index.html": {Data: []byte("index")}}, StaticOptions{})
+
+	tests := []struct {
+		path     string
+		location string
+	}{
+		{"//evil.com/./", "/evil.com/"},
+		{"///evil.com/./", "/evil.com/"},
+		{`/\evil.com/./`, "/evil.com/"},
+		{"/a/./b", "/a/b"},
+	}
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.URL.Path = test.path
+		resp := httptest.NewRecorder()
+		server.ServeHTTP(resp, req)
+
+		if resp.Code != http.StatusMovedPermanently {
+			t.Errorf("%q: expected status %d, got %d", test.path, http.StatusMovedPermanently, resp.Code)
+		}
+		if location := resp.Header().Get("Location"); location != test.location {
+			t.Errorf("%q: expected location %q, got %q", test.path, test.location, location)
+		}
+	}
+}
+
+func TestRedirectUnderStripPrefix(t *testing.T) {
+	server := NewServer(WithPathOptions(PathOptions{CollapseSlashes: true, RedirectCleaned: true, TrailingSlash: TrailingSlashRedirect}))
+	server.Handle(PathDetail{Method: http.MethodGet, PathRegex: "/users"}, paramHandler{})
+	server.Handle(PathDetail{Method: http.MethodPost, PathRegex: "/users/{id}/orders/"}, paramHandler{})
+	mounted := http.StripPrefix("/api", server)
+
+	tests := []struct {
+		method   string
+		target   string
+		status   int
+		location string
+	}{
+		{http.MethodGet, "/api/users/", http.StatusMovedPermanently, "/api/users"},
+		{http.MethodGet, "/api/users/?page=2", http.StatusMovedPermanently, "/api/users?page=2"},
+		{http.MethodGet, "/api//users", http.StatusMovedPermanently, "/api/users"},
+		{http.MethodPost, "/api/users/a%20b/orders", http.StatusPermanentRedirect, "/api/users/a%20b/orders/"},
+	}
+	for _, test := range tests {
+		resp := httptest.NewRecorder()
+		mounted.ServeHTTP(resp, httptest.NewRequest(test.method, test.target, nil))
+		if resp.Code != test.status {
+			t.Errorf("%s %s: expected status %d, got %d", test.method, test.target, test.status, resp.Code)
+		}
+		if location := resp.Header().Get("Location"); location != test.location {
+			t.Errorf("%s %s: expected location %q, got %q", test.method, test.target, test.location, location)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package charon
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// segmentKind the kind of a single segment of a path template
+type segmentKind int
+
+const (
+	staticSegment segmentKind = iota
+	paramSegment
+	wildcardSegment
+)
+
+// patternSegment a single "/" separated segment of a path template
+type patternSegment struct {
+	kind       segmentKind
+	value      string // the literal for static segments, the param name otherwise
+	constraint *regexp.Regexp
+}
+
+// pathPattern is the compiled form of PathDetail.PathRegex.
+//
+// A pattern is either a template or a regular expression. Templates are "/"
+// separated segments where each segment is a literal ("users"), a named
+// parameter ("{id}"), a named parameter constrained by a regular expression
+// ("{id:[0-9]+}") or, as the last segment, a catch-all ("*filepath") that
+// captures the rest of the path. Parameters always span a whole segment and
+// constraints are matched against that segment only.
+// Patterns starting with "^" are regular expressions matched against the whole
+// path, named groups of the expression are exposed as path params.
+type pathPattern struct {
+	raw      string
+	segments []patternSegment
+	regex    *regexp.Regexp
+}
+
+// compilePathPattern parses and compiles the given PathRegex, regular expressions
+// are compiled to match case insensitively if ignoreCase is set
+func compilePathPattern(pattern string, ignoreCase bool) (*pathPattern, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("charon: empty path pattern")
+	}
+
+	if strings.HasPrefix(pattern, "^") {
+		// anchoring the end as well, so that the expression cannot match a mere prefix of the path
+		expr := "^(?:" + pattern[1:] + ")$"
+		if ignoreCase {
+			expr = "(?i)" + expr
+		}
+		regex, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("charon: invalid path regex %q: %s", pattern, err.Error())
+		}
+		return &pathPattern{raw: pattern, regex: regex}, nil
+	}
+	if !strings.HasPrefix(pattern, "/") {
+		return nil, fmt.Errorf("charon: invalid path pattern %q: must start with \"/\"", pattern)
+	}
+
+	parts := strings.Split(pattern, "/")
+	segments := make([]patternSegment, 0, len(parts))
+	for i, part := range parts {
+		segment, err := parseSegment(part)
+		if err != nil {
+			return nil, fmt.Errorf("charon: invalid path pattern %q: %s", pattern, err.Error())
+		}
+		if segment.kind == wildcardSegment && i != len(parts)-1 {
+			return nil, fmt.Errorf("charon: invalid path pattern %q: catch-all %q must be the last segment", pattern, part)
+		}
+		segments = append(segments, segment)
+	}
+	return &pathPattern{raw: pattern, segments: segments}, nil
+}
+
+// parseSegment parses a single segment of a path template
+func parseSegment(segment string) (patternSegment, error) {
+	if strings.HasPrefix(segment, "*") {
+		name := segment[1:]
+		if !isValidParamName(name) {
+			return patternSegment{}, fmt.Errorf("invalid catch-all name %q", name)
+		}
+		return patternSegment{kind: wildcardSegment, value: name}, nil
+	}
+
+	if !strings.HasPrefix(segment, "{") {
+		if strings.ContainsAny(segment, "{}") {
+			return patternSegment{}, fmt.Errorf("parameter %q must span the whole segment", segment)
+		}
+		return patternSegment{kind: staticSegment, value: segment}, nil
+	}
+	if !strings.HasSuffix(segment, "}") {
+		return patternSegment{}, fmt.Errorf("parameter %q must span the whole segment", segment)
+	}
+
+	inner := segment[1 : len(segment)-1]
+	name, constraint := inner, ""
+	if idx := strings.Index(inner, ":"); idx >= 0 {
+		name, constraint = inner[:idx], inner[idx+1:]
+		if constraint == "" {
+			return patternSegment{}, fmt.Errorf("empty constraint for parameter %q", name)
+		}
+	}
+	if !isValidParamName(name) {
+		return patternSegment{}, fmt.Errorf("invalid parameter name %q", name)
+	}
+
+	parsed := patternSegment{kind: paramSegment, value: name}
+	if constraint != "" {
+		regex, err := regexp.Compile("^(?:" + constraint + ")$")
+		if err != nil {
+			return patternSegment{}, fmt.Errorf("invalid constraint for parameter %q: %s", name, err.Error())
+		}
+		parsed.constraint = regex
+	}
+	return parsed, nil
+}
+
+// isValidParamName checks if the name can be used as a path param name
+func isValidParamName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// matchRegex matches the given path against a regular expression pattern, returning the captured path params
+func (pattern *pathPattern) matchRegex(path string) (map[string]string, bool) {
+	matches := pattern.regex.FindStringSubmatch(path)
+	if matches == nil {
+		return nil, false
+	}
+	var params map[string]string
+	for i, name := range pattern.regex.SubexpNames() {
+		if i == 0 || name == "" {
+			continue
+		}
+		if params == nil {
+			params = make(map[string]string)
+		}
+		params[name] = matches[i]
+	}
+	return params, true
+}
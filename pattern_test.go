@@ -0,0 +1,61 @@
+package charon
+
+import (
+	"testing"
+)
+
+func TestCompilePathPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		valid   bool
+	}{
+		{"/users/{id}/orders/{orderId:[0-9]+}", true},
+		{"/assets/*filepath", true},
+		{"/", true},
+		{`^/re/(?P<id>[0-9]+)`, true},
+		{"", false},
+		{"users", false},
+		{"users/{id}", false},
+		{"{id}", false},
+		{"/users/{id", false},
+		{"/users/x{id}", false},
+		{"/users/{id:}", false},
+		{"/users/{1id}", false},
+		{"/assets/*filepath/more", false},
+		{`^/re/(`, false},
+	}
+	for _, test := range tests {
+		if _, err := compilePathPattern(test.pattern, false); (err == nil) != test.valid {
+			t.Errorf("%q: expected valid %t, got %v", test.pattern, test.valid, err)
+		}
+	}
+}
+
+func TestMatchRegex(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		ignoreCase bool
+		path       string
+		matches    bool
+		id         string
+	}{
+		{`^/re/(?P<id>[0-9]+)`, false, "/re/12", true, "12"},
+		{`^/re/(?P<id>[0-9]+)`, false, "/re/12/anything", false, ""},
+		{`^/re/(?P<id>[0-9]+)`, false, "/re/12a", false, ""},
+		{`^/re/(?P<id>[0-9]+)$`, false, "/re/12", true, "12"},
+		{`^/a|/b`, false, "/b", true, ""},
+		{`^/a|/b`, false, "/a/b", false, ""},
+		{`^/RE/(?P<id>[0-9]+)`, true, "/re/7", true, "7"},
+		{`^/RE/(?P<id>[0-9]+)`, false, "/re/7", false, ""},
+	}
+	for _, test := range tests {
+		pattern, err := compilePathPattern(test.pattern, test.ignoreCase)
+		if err != nil {
+			t.Fatalf("%q: %s", test.pattern, err.Error())
+		}
+		params, matches := pattern.matchRegex(test.path)
+		if matches != test.matches || params["id"] != test.id {
+			t.Errorf("%q against %q: expected %t %q, got %t %v", test.pattern, test.path, test.matches, test.id, matches, params)
+		}
+	}
+}
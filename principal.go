@@ -0,0 +1,126 @@
+package charon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/charon/errors"
+)
+
+// Principal the authenticated client of a request. The authentication stores it in the context it
+// returns with WithPrincipal, the access policies of the routes are checked against it
+type Principal struct {
+	Subject string
+	Roles   []string
+	Scopes  []string
+	Claims  map[string]interface{}
+}
+
+// HasRole checks if the principal has the role
+func (principal *Principal) HasRole(role string) bool {
+	return principal != nil && contains(principal.Roles, role)
+}
+
+// HasScope checks if the principal has the scope
+func (principal *Principal) HasScope(scope string) bool {
+	return principal != nil && contains(principal.Scopes, scope)
+}
+
+// Claim returns the named claim of the principal, nil if not present
+func (principal *Principal) Claim(name string) interface{} {
+	if principal == nil {
+		return nil
+	}
+	return principal.Claims[name]
+}
+
+// WithPrincipal returns a copy of the context holding the principal, to be returned by the authentication
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey, principal)
+}
+
+// PrincipalFromContext returns the principal stored in the context by the authentication, nil if not present
+func PrincipalFromContext(ctx context.Context) *Principal {
+	if ctx == nil {
+		return nil
+	}
+	principal, _ := ctx.Value(principalKey).(*Principal)
+	return principal
+}
+
+//Principal returns the principal of the incoming http request as set by the authentication. For the
+//authentications returning a *url.Userinfo only, the principal has the username as its subject
+func (detail RouteDetails) Principal() *Principal {
+	if principal := PrincipalFromContext(detail.ctx); principal != nil {
+		return principal
+	}
+	if detail.req != nil && detail.req.URL.User != nil {
+		return &Principal{Subject: detail.req.URL.User.Username()}
+	}
+	return nil
+}
+
+// AccessPolicy the access requirements of a route, checked against the principal of the request
+// after the authentication and before the validation, see PathDetail.Access
+type AccessPolicy struct {
+	// Roles the principal must have one of, if any
+	Roles []string
+
+	// Scopes the principal must have all of
+	Scopes []string
+
+	// Policy checks the request once the roles and scopes are satisfied, the principal is nil for
+	// unauthenticated requests. Errors other than errors.AuthorizationError are returned as is
+	Policy func(*Principal, RouteDetails) errors.Error
+}
+
+// authorize checks the principal of the request against the policy, returns an errors.AuthorizationError if denied
+func authorize(access *AccessPolicy, rDetails RouteDetails) errors.Error {
+	if access == nil {
+		return nil
+	}
+	principal := rDetails.Principal()
+	if (len(access.Roles) > 0 || len(access.Scopes) > 0) && principal == nil {
+		return errors.AuthorizationError{Err: "No principal for the request", Mess: "Not authorized"}
+	}
+
+	if len(access.Roles) > 0 {
+		allowed := false
+		for _, role := range access.Roles {
+			if principal.HasRole(role) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return errors.AuthorizationError{
+				Err:  fmt.Sprint("Principal ", principal.Subject, " has none of the roles ", strings.Join(access.Roles, ", ")),
+				Mess: "Not authorized",
+			}
+		}
+	}
+	for _, scope := range access.Scopes {
+		if !principal.HasScope(scope) {
+			return errors.AuthorizationError{
+				Err:  fmt.Sprint("Principal ", principal.Subject, " is missing the scope ", scope),
+				Mess: "Not authorized",
+			}
+		}
+	}
+
+	if access.Policy != nil {
+		return access.Policy(principal, rDetails)
+	}
+	return nil
+}
+
+// contains checks if the values hold the value
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,165 @@
+package charon
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charon/errors"
+)
+
+// QueryOptions configures how the query params of GET and HEAD requests, and the values of form bodies,
+// are set on RouteDetails.Body. With all the options set the body has the same shape as a JSON body.
+// By default every param is set as a []string
+type QueryOptions struct {
+	// Scalars sets the params with a single value as that value instead of a one value slice,
+	// repeated params are set as a []interface{}
+	Scalars bool
+
+	// Types converts the values that are JSON numbers to float64 and the "true" and "false" values to bool,
+	// as with JSON bodies
+	Types bool
+
+	// Nested parses the "filter[status]=open" params into nested maps, and the "ids[]=1&ids[]=2" params into
+	// a []interface{}, whatever the Scalars option. Params conflicting with each other, like "a=1&a[b]=2",
+	// are rejected with an errors.InvalidInputError
+	Nested bool
+}
+
+// jsonNumber matches the values that are JSON numbers, values like "007" or "0x10" are kept as strings
+var jsonNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// WithQueryOptions sets how the query params and form values are set on the body of the requests
+func WithQueryOptions(queryOptions QueryOptions) Option {
+	return func(server *Server) {
+		server.queryOptions = queryOptions
+	}
+}
+
+// toBody returns the body holding the values as per the options
+func (options QueryOptions) toBody(values url.Values) (map[string]interface{}, errors.Error) {
+	body := make(map[string]interface{}, len(values))
+	if !options.Scalars && !options.Types && !options.Nested {
+		for k, v := range values {
+			body[k] = v
+		}
+		return body, nil
+	}
+
+	// the keys are sorted, so that the conflicts are reported deterministically
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		path, isArray := []string{key}, false
+		if options.Nested {
+			path, isArray = splitNestedKey(key)
+		}
+		var value interface{}
+		if isArray {
+			value = options.convertAll(values[key])
+		} else {
+			value = options.convert(values[key])
+		}
+		if err := setNested(body, path, value); err != nil {
+			return nil, errors.InvalidInputError{Err: fmt.Sprint("Invalid param ", key, " : ", err.Error()), Mess: "Invalid params"}
+		}
+	}
+	return body, nil
+}
+
+// convert returns the value of a param as per the Scalars and Types options
+func (options QueryOptions) convert(values []string) interface{} {
+	if options.Scalars && len(values) == 1 {
+		return options.convertOne(values[0])
+	}
+	if !options.Scalars && !options.Types {
+		return values
+	}
+	return options.convertAll(values)
+}
+
+// convertAll returns the values of a param as a []interface{}, converted as per the Types option
+func (options QueryOptions) convertAll(values []string) []interface{} {
+	converted := make([]interface{}, 0, len(values))
+	for _, value := range values {
+		converted = append(converted, options.convertOne(value))
+	}
+	return converted
+}
+
+// convertOne converts a single value as per the Types option
+func (options QueryOptions) convertOne(value string) interface{} {
+	if !options.Types {
+		return value
+	}
+	switch value {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if jsonNumber.MatchString(value) {
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			return number
+		}
+	}
+	return value
+}
+
+// splitNestedKey splits the "a[b][c]" key into its path, a trailing "[]" marks an array.
+// Keys that are not well formed are kept as is
+func splitNestedKey(key string) ([]string, bool) {
+	idx := strings.Index(key, "[")
+	if idx <= 0 || !strings.HasSuffix(key, "]") {
+		return []string{key}, false
+	}
+
+	path := []string{key[:idx]}
+	rest := key[idx:]
+	isArray := false
+	for rest != "" {
+		end := strings.Index(rest, "]")
+		if !strings.HasPrefix(rest, "[") || end < 0 || isArray {
+			return []string{key}, false
+		}
+		if name := rest[1:end]; name != "" {
+			path = append(path, name)
+		} else {
+			isArray = true
+		}
+		rest = rest[end+1:]
+	}
+	return path, isArray
+}
+
+// setNested sets the value in the body under the path, creating the intermediate maps
+func setNested(body map[string]interface{}, path []string, value interface{}) error {
+	current := body
+	for i, name := range path {
+		if i == len(path)-1 {
+			if _, exists := current[name]; exists {
+				return fmt.Errorf("conflicts with the params nested under %s", name)
+			}
+			current[name] = value
+			return nil
+		}
+		switch next := current[name].(type) {
+		case nil:
+			nested := make(map[string]interface{})
+			current[name] = nested
+			current = nested
+		case map[string]interface{}:
+			current = next
+		default:
+			return fmt.Errorf("conflicts with the param %s", strings.Join(path[:i+1], "."))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package charon
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// headResponseWriter answers a HEAD request with the response of the GET handler,
+// dropping the body while keeping its Content-Length
+type headResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	length      int
+	wroteHeader bool
+}
+
+// WriteHeader records the status code, to be written once the body length is known
+func (resp *headResponseWriter) WriteHeader(status int) {
+	if !resp.wroteHeader {
+		resp.status = status
+		resp.wroteHeader = true
+	}
+}
+
+// Write discards the body, counting its length
+func (resp *headResponseWriter) Write(body []byte) (int, error) {
+	resp.wroteHeader = true
+	resp.length += len(body)
+	return len(body), nil
+}
+
+// flush writes the status code along with the Content-Length of the dropped body
+func (resp *headResponseWriter) flush() {
+	header := resp.ResponseWriter.Header()
+	if header.Get("Content-Length") == "" && resp.status != http.StatusNoContent && resp.status != http.StatusNotModified {
+		header.Set("Content-Length", strconv.Itoa(resp.length))
+	}
+	resp.ResponseWriter.WriteHeader(resp.status)
+}
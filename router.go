@@ -0,0 +1,311 @@
+package charon
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// route a registered handler along with its compiled path pattern
+type route struct {
+	detail  PathDetail
+	host    *hostPattern
+	pattern *pathPattern
+	handler Handler
+	config  routeConfig
+
+	// httpHandler serves the route in place of the handler, for routes registered with HandleHTTP
+	httpHandler http.Handler
+
+	// in-flight requests, tracked so that removing the route can wait for them to complete
+	mu      sync.Mutex
+	active  int
+	closed  bool
+	drained chan struct{}
+}
+
+// acquire marks a request in-flight on the route, returns false if the route has been
+// removed from the route table, in which case the request must look up the route again
+func (rt *route) acquire() bool {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if rt.closed {
+		return false
+	}
+	rt.active++
+	return true
+}
+
+// release marks an in-flight request of the route complete
+func (rt *route) release() {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.active--
+	if rt.closed && rt.active == 0 {
+		close(rt.drained)
+	}
+}
+
+// close stops the route from accepting new requests, the returned channel is
+// closed once all the in-flight requests of the route are complete
+func (rt *route) close() <-chan struct{} {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	if !rt.closed {
+		rt.closed = true
+		rt.drained = make(chan struct{})
+		if rt.active == 0 {
+			close(rt.drained)
+		}
+	}
+	return rt.drained
+}
+
+// methodRoutes the routes registered against a single path, keyed by http method
+type methodRoutes map[string]*route
+
+// router is a prefix tree over the "/" separated segments of the registered path templates.
+//
+// Lookups walk the tree one segment at a time and are independent of the number of routes.
+// At every level static segments take precedence over parameters, constrained parameters
+// over unconstrained ones and parameters over catch-alls, backtracking when a branch
+// does not lead to a registered path. Routes registered as regular expressions are
+// tried after the tree, in the order they were added.
+// Static segments are matched case insensitively if ignoreCase is set.
+type router struct {
+	root       *node
+	regexes    []*regexRoute
+	ignoreCase bool
+}
+
+// node a single segment of the prefix tree
+type node struct {
+	static   map[string]*node
+	params   []*paramEdge
+	wildcard *wildcardEdge
+	routes   methodRoutes
+}
+
+// paramEdge a path param leading to a child node
+type paramEdge struct {
+	segment patternSegment
+	child   *node
+}
+
+// wildcardEdge a catch-all capturing the rest of the path
+type wildcardEdge struct {
+	name   string
+	routes methodRoutes
+}
+
+// regexRoute the routes registered against a single regular expression
+type regexRoute struct {
+	pattern *pathPattern
+	routes  methodRoutes
+}
+
+// paramValue a captured path param
+type paramValue struct {
+	name  string
+	value string
+}
+
+// newRouter creates and returns an empty router
+func newRouter(ignoreCase bool) *router {
+	return &router{root: &node{}, ignoreCase: ignoreCase}
+}
+
+// add registers the route in the router, returns an error if a route is already
+// registered for the same method and path
+func (r *router) add(rt *route) error {
+	pattern := rt.pattern
+	if pattern.regex != nil {
+		for _, entry := range r.regexes {
+			if entry.pattern.raw == pattern.raw {
+				return entry.routes.add(rt)
+			}
+		}
+		entry := &regexRoute{pattern: pattern, routes: methodRoutes{}}
+		r.regexes = append(r.regexes, entry)
+		return entry.routes.add(rt)
+	}
+
+	current := r.root
+	for _, segment := range pattern.segments {
+		switch segment.kind {
+		case staticSegment:
+			if current.static == nil {
+				current.static = make(map[string]*node)
+			}
+			key := segment.value
+			if r.ignoreCase {
+				key = strings.ToLower(key)
+			}
+			child, ok := current.static[key]
+			if !ok {
+				child = &node{}
+				current.static[key] = child
+			}
+			current = child
+		case paramSegment:
+			current = current.paramChild(segment)
+		case wildcardSegment:
+			if current.wildcard == nil {
+				current.wildcard = &wildcardEdge{name: segment.value, routes: methodRoutes{}}
+			} else if current.wildcard.name != segment.value {
+				return fmt.Errorf("charon: catch-all %q in %q conflicts with existing catch-all %q",
+					segment.value, pattern.raw, current.wildcard.name)
+			}
+			return current.wildcard.routes.add(rt)
+		}
+	}
+	if current.routes == nil {
+		current.routes = methodRoutes{}
+	}
+	return current.routes.add(rt)
+}
+
+// add adds the route against its method
+func (routes methodRoutes) add(rt *route) error {
+	if existing, ok := routes[rt.detail.Method]; ok {
+		return fmt.Errorf("charon: route %s %s conflicts with %s %s", rt.detail.Method, rt.detail.PathRegex,
+			existing.detail.Method, existing.detail.PathRegex)
+	}
+	routes[rt.detail.Method] = rt
+	return nil
+}
+
+// serves checks if one of the routes serves the method, HEAD requests being served by the GET route
+func (routes methodRoutes) serves(method string) bool {
+	if _, ok := routes[method]; ok {
+		return true
+	}
+	_, ok := routes[http.MethodGet]
+	return ok && method == http.MethodHead
+}
+
+// allowed returns the sorted list of methods served, including the HEAD and OPTIONS
+// methods charon answers on its own
+func (routes methodRoutes) allowed() []string {
+	methods := make([]string, 0, len(routes)+2)
+	for method := range routes {
+		methods = append(methods, method)
+	}
+	if _, ok := routes[http.MethodOptions]; !ok {
+		methods = append(methods, http.MethodOptions)
+	}
+	if _, ok := routes[http.MethodHead]; !ok {
+		if _, ok = routes[http.MethodGet]; ok {
+			methods = append(methods, http.MethodHead)
+		}
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// paramChild returns the child node for the given param segment, creating it if needed.
+// Constrained params are kept ahead of unconstrained ones, otherwise in the order added
+func (n *node) paramChild(segment patternSegment) *node {
+	for _, edge := range n.params {
+		if edge.segment.value == segment.value && sameConstraint(edge.segment, segment) {
+			return edge.child
+		}
+	}
+
+	edge := &paramEdge{segment: segment, child: &node{}}
+	idx := len(n.params)
+	if segment.constraint != nil {
+		for i, existing := range n.params {
+			if existing.segment.constraint == nil {
+				idx = i
+				break
+			}
+		}
+	}
+	n.params = append(n.params, nil)
+	copy(n.params[idx+1:], n.params[idx:])
+	n.params[idx] = edge
+	return edge.child
+}
+
+// sameConstraint checks if both the segments have the same constraint
+func sameConstraint(a, b patternSegment) bool {
+	if a.constraint == nil || b.constraint == nil {
+		return a.constraint == nil && b.constraint == nil
+	}
+	return a.constraint.String() == b.constraint.String()
+}
+
+// lookup finds the routes registered against the given path along with the captured path params,
+// returns nil routes if no route matches the path
+func (r *router) lookup(path string) (methodRoutes, map[string]string) {
+	if routes, values := r.root.match(path, r.ignoreCase, nil); routes != nil {
+		return routes, toParamMap(values)
+	}
+	for _, entry := range r.regexes {
+		if params, ok := entry.pattern.matchRegex(path); ok {
+			return entry.routes, params
+		}
+	}
+	return nil, nil
+}
+
+// match matches the remaining path against the subtree of this node
+func (n *node) match(path string, ignoreCase bool, values []paramValue) (methodRoutes, []paramValue) {
+	segment, rest, hasRest := path, "", false
+	if idx := strings.IndexByte(path, '/'); idx >= 0 {
+		segment, rest, hasRest = path[:idx], path[idx+1:], true
+	}
+
+	key := segment
+	if ignoreCase {
+		key = strings.ToLower(segment)
+	}
+	if child, ok := n.static[key]; ok {
+		if routes, matched := child.next(rest, hasRest, ignoreCase, values); routes != nil {
+			return routes, matched
+		}
+	}
+
+	if segment != "" {
+		for _, edge := range n.params {
+			if edge.segment.constraint != nil && !edge.segment.constraint.MatchString(segment) {
+				continue
+			}
+			captured := append(values, paramValue{name: edge.segment.value, value: segment})
+			if routes, matched := edge.child.next(rest, hasRest, ignoreCase, captured); routes != nil {
+				return routes, matched
+			}
+		}
+	}
+
+	if n.wildcard != nil && len(n.wildcard.routes) > 0 {
+		return n.wildcard.routes, append(values, paramValue{name: n.wildcard.name, value: path})
+	}
+	return nil, values
+}
+
+// next continues matching from this node, or finishes the match if the path is exhausted
+func (n *node) next(rest string, hasRest bool, ignoreCase bool, values []paramValue) (methodRoutes, []paramValue) {
+	if !hasRest {
+		if len(n.routes) > 0 {
+			return n.routes, values
+		}
+		return nil, values
+	}
+	return n.match(rest, ignoreCase, values)
+}
+
+// toParamMap converts the captured values into the path params map
+func toParamMap(values []paramValue) map[string]string {
+	if len(values) == 0 {
+		return nil
+	}
+	params := make(map[string]string, len(values))
+	for _, value := range values {
+		params[value.name] = value.value
+	}
+	return params
+}
@@ -0,0 +1,139 @@
+package charon
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// newTestRouter returns a router holding GET routes for the path patterns
+func newTestRouter(t *testing.T, ignoreCase bool, patterns ...string) *router {
+	r := newRouter(ignoreCase)
+	for _, pattern := range patterns {
+		if err := r.add(newTestRoute(t, http.MethodGet, pattern, ignoreCase)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r
+}
+
+// newTestRoute returns the route of the method and path pattern
+func newTestRoute(t *testing.T, method, pattern string, ignoreCase bool) *route {
+	compiled, err := compilePathPattern(pattern, ignoreCase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &route{detail: PathDetail{Method: method, PathRegex: pattern}, pattern: compiled}
+}
+
+func TestRouterLookup(t *testing.T) {
+	r := newTestRouter(t, false,
+		"/users/new",
+		"/users/{id:[0-9]+}",
+		"/users/{name}",
+		"/users/{name}/posts",
+		"/users/*rest",
+		"/a/{x}/c",
+		"/a/b/d",
+		"/files/*filepath",
+		"/re/{name}",
+		`^/re/(?P<id>[0-9]+)`,
+		`^/re/(?P<id>[0-9]+)/more`,
+	)
+
+	tests := []struct {
+		path    string
+		pattern string
+		params  map[string]string
+	}{
+		// static > constrained param > param > catch-all
+		{"/users/new", "/users/new", nil},
+		{"/users/12", "/users/{id:[0-9]+}", map[string]string{"id": "12"}},
+		{"/users/bob", "/users/{name}", map[string]string{"name": "bob"}},
+		{"/users/bob/x/y", "/users/*rest", map[string]string{"rest": "bob/x/y"}},
+		{"/users/", "/users/*rest", map[string]string{"rest": ""}},
+
+		// backtracking when the preferred branch does not lead to a route
+		{"/users/new/posts", "/users/{name}/posts", map[string]string{"name": "new"}},
+		{"/users/12/posts", "/users/{name}/posts", map[string]string{"name": "12"}},
+		{"/a/b/c", "/a/{x}/c", map[string]string{"x": "b"}},
+		{"/a/b/d", "/a/b/d", nil},
+		{"/a/z/d", "", nil},
+
+		// catch-alls
+		{"/files/css/site.css", "/files/*filepath", map[string]string{"filepath": "css/site.css"}},
+		{"/files/", "/files/*filepath", map[string]string{"filepath": ""}},
+		{"/files", "", nil},
+
+		// regular expressions are tried after the tree
+		{"/re/12", "/re/{name}", map[string]string{"name": "12"}},
+		{"/re/12/more", `^/re/(?P<id>[0-9]+)/more`, map[string]string{"id": "12"}},
+		{"/re/12/other", "", nil},
+
+		{"/", "", nil},
+		{"/nothing", "", nil},
+		{"/USERS/new", "", nil},
+	}
+	for _, test := range tests {
+		routes, params := r.lookup(test.path)
+		pattern := ""
+		if routes != nil {
+			pattern = routes[http.MethodGet].detail.PathRegex
+		}
+		if pattern != test.pattern || !reflect.DeepEqual(params, test.params) {
+			t.Errorf("%s: expected %q %v, got %q %v", test.path, test.pattern, test.params, pattern, params)
+		}
+	}
+}
+
+func TestRouterIgnoreCase(t *testing.T) {
+	r := newTestRouter(t, true, "/Users/{Name}", "/users/new", `^/RE/(?P<id>[0-9]+)`)
+	tests := []struct {
+		path    string
+		pattern string
+		params  map[string]string
+	}{
+		{"/USERS/NEW", "/users/new", nil},
+		{"/users/Bob", "/Users/{Name}", map[string]string{"Name": "Bob"}},
+		{"/re/1", `^/RE/(?P<id>[0-9]+)`, map[string]string{"id": "1"}},
+	}
+	for _, test := range tests {
+		routes, params := r.lookup(test.path)
+		pattern := ""
+		if routes != nil {
+			pattern = routes[http.MethodGet].detail.PathRegex
+		}
+		if pattern != test.pattern || !reflect.DeepEqual(params, test.params) {
+			t.Errorf("%s: expected %q %v, got %q %v", test.path, test.pattern, test.params, pattern, params)
+		}
+	}
+}
+
+func TestRouterAdd(t *testing.T) {
+	tests := []struct {
+		name   string
+		routes [][2]string
+		valid  bool
+	}{
+		{"methods of a path", [][2]string{{"GET", "/users/{id}"}, {"POST", "/users/{id}"}}, true},
+		{"same method and path", [][2]string{{"GET", "/users/{id}"}, {"GET", "/users/{id}"}}, false},
+		{"same method, other param name", [][2]string{{"GET", "/users/{id}"}, {"GET", "/users/{name}"}}, true},
+		{"same method, other constraint", [][2]string{{"GET", "/users/{id:[0-9]+}"}, {"GET", "/users/{id}"}}, true},
+		{"same catch-all", [][2]string{{"GET", "/files/*path"}, {"GET", "/files/*path"}}, false},
+		{"other catch-all name", [][2]string{{"GET", "/files/*path"}, {"POST", "/files/*name"}}, false},
+		{"same regex", [][2]string{{"GET", "^/re/[0-9]+"}, {"GET", "^/re/[0-9]+"}}, false},
+		{"methods of a regex", [][2]string{{"GET", "^/re/[0-9]+"}, {"POST", "^/re/[0-9]+"}}, true},
+	}
+	for _, test := range tests {
+		r := newRouter(false)
+		var err error
+		for _, rt := range test.routes {
+			if err = r.add(newTestRoute(t, rt[0], rt[1], false)); err != nil {
+				break
+			}
+		}
+		if (err == nil) != test.valid {
+			t.Errorf("%s: expected valid %t, got %v", test.name, test.valid, err)
+		}
+	}
+}
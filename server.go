@@ -0,0 +1,321 @@
+package charon
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	logr "github.com/charon/logger"
+)
+
+// Server an isolated charon instance serving its registered routes, implements http.Handler.
+// A Server holds no global state, so several of them can run in the same process, and it
+// can be mounted under a sub-path with http.StripPrefix or served with httptest.NewServer.
+//
+// Routes can be added, replaced and removed while the server is serving requests. Every change
+// builds a new route table that is swapped in atomically, requests always see a consistent table
+type Server struct {
+	logger      *logr.Logger
+	respHandler ResponseHandler
+	pathOptions PathOptions
+
+	maxBodySize     int64
+	multipartMemory int64
+	codecs          codecRegistry
+	decompressors   map[string]Decompressor
+	cookieDefaults  CookieDefaults
+	trustedProxies  []*net.IPNet
+	queryOptions    QueryOptions
+	middlewares     []Middleware
+
+	// pendingRoutes the handlers given with WithRoutes, registered by NewServer once all the options are applied
+	pendingRoutes []map[PathDetail]RouteHandler
+
+	// routesMu serializes the changes to the route table, lookups only load the current table
+	routesMu sync.Mutex
+	routes   []*route
+	table    atomic.Value
+
+	mu         sync.Mutex
+	httpServer *http.Server
+}
+
+// Option configures a Server, to be passed to NewServer
+type Option func(*Server)
+
+// WithLogger sets the logger used by the server, defaults to a logger writing to the console
+func WithLogger(logger *logr.Logger) Option {
+	return func(server *Server) {
+		if logger != nil {
+			server.logger = logger
+		}
+	}
+}
+
+// WithResponseHandler sets the handler used to write all the responses of the server
+func WithResponseHandler(respHandler ResponseHandler) Option {
+	return func(server *Server) {
+		server.respHandler = respHandler
+	}
+}
+
+// WithRoutes registers all the given handlers against the given path and method combo. The handlers are
+// registered once all the options are applied, so the routes are compiled as per WithPathOptions whatever
+// the order of the options
+func WithRoutes(handlers map[PathDetail]RouteHandler) Option {
+	return func(server *Server) {
+		server.pendingRoutes = append(server.pendingRoutes, handlers)
+	}
+}
+
+// WithHTTPServer sets the http.Server used as a template by ListenAndServe and ListenAndServeTLS,
+// to configure timeouts, TLS etc. The Addr and Handler of the given server are overwritten
+func WithHTTPServer(httpServer *http.Server) Option {
+	return func(server *Server) {
+		server.httpServer = httpServer
+	}
+}
+
+// NewServer creates and returns a new Server configured with the given options
+func NewServer(opts ...Option) *Server {
+	server := &Server{
+		logger:          logr.NewLogger("", "", nil, logr.PRODUCTION, logr.Forever),
+		multipartMemory: defaultMultipartMemory,
+		codecs:          newCodecRegistry(),
+		decompressors:   newDecompressors(),
+		cookieDefaults:  defaultCookieDefaults,
+	}
+	for _, opt := range opts {
+		opt(server)
+	}
+	for _, handlers := range server.pendingRoutes {
+		server.handleAll(handlers)
+	}
+	server.pendingRoutes = nil
+	if server.table.Load() == nil {
+		table, _ := newRouteTable(nil, server.pathOptions.IgnoreCase)
+		server.table.Store(table)
+	}
+	return server
+}
+
+// Handle registers the handler against the given path and method combo, configured with the given options,
+// panics if the path pattern is invalid or a handler is already registered for it
+func (server *Server) Handle(pDetail PathDetail, handler Handler, opts ...RouteOption) {
+	if err := server.handle(&route{detail: pDetail, handler: handler, config: newRouteConfig(routeConfig{}, opts)}, false); err != nil {
+		panic(err.Error())
+	}
+}
+
+// handleAll registers all the given handlers against the given path and method combo, panics the same way Handle does
+func (server *Server) handleAll(handlers map[PathDetail]RouteHandler) {
+	// adding the routes in a fixed order, so that the precedence between equally specific routes is deterministic
+	details := make([]PathDetail, 0, len(handlers))
+	for pDetail := range handlers {
+		details = append(details, pDetail)
+	}
+	sort.Slice(details, func(i, j int) bool {
+		if details[i].Host != details[j].Host {
+			return details[i].Host < details[j].Host
+		}
+		if details[i].PathRegex != details[j].PathRegex {
+			return details[i].PathRegex < details[j].PathRegex
+		}
+		return details[i].Method < details[j].Method
+	})
+
+	for _, pDetail := range details {
+		server.Handle(pDetail, handlers[pDetail])
+	}
+}
+
+// HandleHTTP registers a plain http.Handler against the given path and method combo, the handler writes
+// the response itself and can get the route details of the request with RouteDetailsFromContext.
+// Only the authentication of the options applies to the handler, panics the same way Handle does
+func (server *Server) HandleHTTP(pDetail PathDetail, handler http.Handler, opts ...RouteOption) {
+	if err := server.handle(&route{detail: pDetail, httpHandler: handler, config: newRouteConfig(routeConfig{}, opts)}, false); err != nil {
+		panic(err.Error())
+	}
+}
+
+// Static serves the files of fsys under the given path prefix, see NewStaticHandler.
+// Panics the same way Handle does
+func (server *Server) Static(prefix string, fsys fs.FS, staticOptions StaticOptions, opts ...RouteOption) {
+	server.HandleHTTP(PathDetail{Method: http.MethodGet, PathRegex: joinPaths(prefix, "/*filepath")},
+		NewStaticHandler(fsys, staticOptions), opts...)
+}
+
+// Replace registers the handler against the given path and method combo, replacing the handler
+// already registered for it, if any. Requests in-flight on the replaced handler are completed by it.
+// Returns an error if the path pattern is invalid
+func (server *Server) Replace(pDetail PathDetail, handler Handler, opts ...RouteOption) error {
+	return server.handle(&route{detail: pDetail, handler: handler, config: newRouteConfig(routeConfig{}, opts)}, true)
+}
+
+// Remove unregisters the handler registered against the given path and method combo, new requests
+// no longer reach it, and waits for its in-flight requests to complete or for the context to be done.
+// Returns an error if no handler is registered for it, or the error of the context
+func (server *Server) Remove(ctx context.Context, pDetail PathDetail) error {
+	server.routesMu.Lock()
+	idx := server.indexOf(pDetail)
+	if idx < 0 {
+		server.routesMu.Unlock()
+		return fmt.Errorf("charon: no route registered for %s %s%s", pDetail.Method, pDetail.Host, pDetail.PathRegex)
+	}
+	removed := server.routes[idx]
+	routes := make([]*route, 0, len(server.routes)-1)
+	routes = append(routes, server.routes[:idx]...)
+	routes = append(routes, server.routes[idx+1:]...)
+	err := server.swapRoutes(routes)
+	server.routesMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case <-removed.close():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Group creates a group of routes sharing the given path prefix and options
+func (server *Server) Group(prefix string, opts ...RouteOption) *Group {
+	return &Group{server: server, prefix: prefix, config: newRouteConfig(routeConfig{}, opts)}
+}
+
+// handle compiles the route and adds it to the route table, replacing the existing route
+// for the same path and method combo if replace is set
+func (server *Server) handle(added *route, replace bool) error {
+	pDetail := added.detail
+	var err error
+	if added.pattern, err = compilePathPattern(pDetail.PathRegex, server.pathOptions.IgnoreCase); err != nil {
+		return err
+	}
+	if pDetail.Host != "" {
+		if added.host, err = compileHostPattern(pDetail.Host); err != nil {
+			return err
+		}
+	}
+
+	server.routesMu.Lock()
+	defer server.routesMu.Unlock()
+
+	routes := make([]*route, len(server.routes), len(server.routes)+1)
+	copy(routes, server.routes)
+
+	var replaced *route
+	if idx := server.indexOf(pDetail); idx >= 0 && replace {
+		replaced = routes[idx]
+		routes[idx] = added
+	} else {
+		routes = append(routes, added)
+	}
+	if err = server.swapRoutes(routes); err != nil {
+		return err
+	}
+	if replaced != nil {
+		replaced.close()
+	}
+	return nil
+}
+
+// indexOf returns the index of the route registered for the path and method combo, -1 if none,
+// to be called holding routesMu
+func (server *Server) indexOf(pDetail PathDetail) int {
+	for i, rt := range server.routes {
+		if rt.detail.Method == pDetail.Method && rt.detail.PathRegex == pDetail.PathRegex &&
+			strings.EqualFold(rt.detail.Host, pDetail.Host) {
+			return i
+		}
+	}
+	return -1
+}
+
+// swapRoutes builds the route table for the given routes and makes it the current one,
+// to be called holding routesMu
+func (server *Server) swapRoutes(routes []*route) error {
+	table, err := newRouteTable(routes, server.pathOptions.IgnoreCase)
+	if err != nil {
+		return err
+	}
+	server.routes = routes
+	server.table.Store(table)
+	return nil
+}
+
+// lookup finds the route serving the method, host and path in the current route table, along with
+// all the routes registered for the path, the captured host labels and path params. HEAD requests are
+// served by the GET route of the path when no HEAD route is registered. The returned route, if any,
+// has the request marked in-flight and must be released once the request is served
+func (server *Server) lookup(method, host, path string) (methodRoutes, *route, map[string]string, map[string]string) {
+	for {
+		routes, hostParams, params := server.table.Load().(*routeTable).lookup(method, host, path)
+		rt, found := routes[method]
+		if !found && method == http.MethodHead {
+			rt, found = routes[http.MethodGet]
+		}
+		if !found {
+			return routes, nil, hostParams, params
+		}
+		// the route was removed after the table was loaded, looking it up in the new table
+		if rt.acquire() {
+			return routes, rt, hostParams, params
+		}
+	}
+}
+
+// ListenAndServe listens on the given tcp address and serves the registered routes,
+// blocks until the server is shut down
+func (server *Server) ListenAndServe(addr string) error {
+	return server.prepareHTTPServer(addr).ListenAndServe()
+}
+
+// ListenAndServeTLS same as ListenAndServe, but serves https using the given certificate and key files
+func (server *Server) ListenAndServeTLS(addr, certFile, keyFile string) error {
+	return server.prepareHTTPServer(addr).ListenAndServeTLS(certFile, keyFile)
+}
+
+// Shutdown gracefully shuts down the http.Server started by ListenAndServe or ListenAndServeTLS
+func (server *Server) Shutdown(ctx context.Context) error {
+	server.mu.Lock()
+	httpServer := server.httpServer
+	server.mu.Unlock()
+
+	if httpServer == nil {
+		return nil
+	}
+	return httpServer.Shutdown(ctx)
+}
+
+// prepareHTTPServer creates the http.Server managed by this server
+func (server *Server) prepareHTTPServer(addr string) *http.Server {
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	httpServer := &http.Server{}
+	if server.httpServer != nil {
+		httpServer = &http.Server{
+			ReadTimeout:       server.httpServer.ReadTimeout,
+			ReadHeaderTimeout: server.httpServer.ReadHeaderTimeout,
+			WriteTimeout:      server.httpServer.WriteTimeout,
+			IdleTimeout:       server.httpServer.IdleTimeout,
+			MaxHeaderBytes:    server.httpServer.MaxHeaderBytes,
+			TLSConfig:         server.httpServer.TLSConfig,
+			ErrorLog:          server.httpServer.ErrorLog,
+			BaseContext:       server.httpServer.BaseContext,
+			ConnContext:       server.httpServer.ConnContext,
+		}
+	}
+	httpServer.Addr = addr
+	httpServer.Handler = server
+	server.httpServer = httpServer
+	return httpServer
+}
@@ -0,0 +1,232 @@
+package charon
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/charon/errors"
+)
+
+// paramHandler a RouteHandler answering with the id path param
+type paramHandler struct{}
+
+func (paramHandler) IsAuthenticated(ctx context.Context, header http.Header) (context.Context, *url.Userinfo, errors.Error) {
+	return ctx, nil, nil
+}
+
+func (paramHandler) IsValidInput(RouteDetails) errors.Error {
+	return nil
+}
+
+func (paramHandler) HandleCall(detail *RouteDetails) ([]byte, errors.Error) {
+	return []byte(detail.PathParam("id")), nil
+}
+
+func TestWithRoutesOptionOrder(t *testing.T) {
+	routes := map[PathDetail]RouteHandler{{Method: http.MethodGet, PathRegex: "/Users/{id}"}: paramHandler{}}
+	pathOptions := WithPathOptions(PathOptions{IgnoreCase: true})
+	servers := map[string]*Server{
+		"routes first":       NewServer(WithRoutes(routes), pathOptions),
+		"path options first": NewServer(pathOptions, WithRoutes(routes)),
+	}
+	for name, server := range servers {
+		resp := httptest.NewRecorder()
+		server.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "/users/7", nil))
+		if resp.Code != http.StatusOK || resp.Body.String() != "7" {
+			t.Errorf("%s: expected 200 7, got %d %s", name, resp.Code, resp.Body.String())
+		}
+	}
+}
+
+func TestWithRoutesConflict(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a route registered twice")
+		}
+	}()
+	routes := map[PathDetail]RouteHandler{{Method: http.MethodGet, PathRegex: "/users/{id}"}: paramHandler{}}
+	NewServer(WithRoutes(routes), WithRoutes(routes))
+}
+
+// blockingHandler a handler signaling it was entered, then blocking until released
+type blockingHandler struct {
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (handler blockingHandler) HandleCall(detail *RouteDetails) ([]byte, errors.Error) {
+	handler.entered <- struct{}{}
+	<-handler.release
+	return []byte("done"), nil
+}
+
+// serve serves a GET request for the path and returns the response
+func serve(server *Server, path string) *httptest.ResponseRecorder {
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, path, nil))
+	return resp
+}
+
+// startBlocked serves a request on the blocking route of the server in the background, and returns
+// once the request is in the handler. The response is sent on the returned channel once served
+func startBlocked(t *testing.T, server *Server, handler blockingHandler) <-chan *httptest.ResponseRecorder {
+	served := make(chan *httptest.ResponseRecorder, 1)
+	go func() {
+		served <- serve(server, "/slow")
+	}()
+	select {
+	case <-handler.entered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request did not reach the handler")
+	}
+	return served
+}
+
+// waitRemoved waits for the route of the path to be gone from the route table
+func waitRemoved(t *testing.T, server *Server, path string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		_, rt, _, _ := server.lookup(http.MethodGet, "", path)
+		if rt == nil {
+			return
+		}
+		rt.release()
+		if time.Now().After(deadline) {
+			t.Fatalf("%s was not removed from the route table", path)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRemoveWaitsForInFlightRequests(t *testing.T) {
+	server := NewServer()
+	handler := blockingHandler{entered: make(chan struct{}), release: make(chan struct{})}
+	slow := PathDetail{Method: http.MethodGet, PathRegex: "/slow"}
+	server.Handle(slow, handler)
+
+	served := startBlocked(t, server, handler)
+	removed := make(chan error, 1)
+	go func() {
+		removed <- server.Remove(context.Background(), slow)
+	}()
+
+	waitRemoved(t, server, "/slow")
+	if resp := serve(server, "/slow"); resp.Code != http.StatusNotFound {
+		t.Errorf("expected new requests to get %d, got %d", http.StatusNotFound, resp.Code)
+	}
+	select {
+	case err := <-removed:
+		t.Fatalf("expected Remove to wait for the in-flight request, returned %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(handler.release)
+	if err := <-removed; err != nil {
+		t.Errorf("expected Remove to succeed, got %s", err.Error())
+	}
+	if resp := <-served; resp.Code != http.StatusOK || resp.Body.String() != "done" {
+		t.Errorf("expected the in-flight request to complete, got %d %s", resp.Code, resp.Body.String())
+	}
+	if err := server.Remove(context.Background(), slow); err == nil {
+		t.Error("expected an error removing a route no longer registered")
+	}
+}
+
+func TestRemoveContextDone(t *testing.T) {
+	server := NewServer()
+	handler := blockingHandler{entered: make(chan struct{}), release: make(chan struct{})}
+	slow := PathDetail{Method: http.MethodGet, PathRegex: "/slow"}
+	server.Handle(slow, handler)
+
+	served := startBlocked(t, server, handler)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := server.Remove(ctx, slow); err != context.DeadlineExceeded {
+		t.Errorf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+	if resp := serve(server, "/slow"); resp.Code != http.StatusNotFound {
+		t.Errorf("expected the route to be removed all the same, got %d", resp.Code)
+	}
+
+	close(handler.release)
+	if resp := <-served; resp.Code != http.StatusOK {
+		t.Errorf("expected the in-flight request to complete, got %d", resp.Code)
+	}
+}
+
+// countingHandler a handler counting its calls, answering with its name
+type countingHandler struct {
+	name  string
+	calls *int64
+}
+
+func (handler countingHandler) HandleCall(detail *RouteDetails) ([]byte, errors.Error) {
+	atomic.AddInt64(handler.calls, 1)
+	return []byte(handler.name), nil
+}
+
+func TestConcurrentReplaceRemove(t *testing.T) {
+	server := NewServer()
+	pDetail := PathDetail{Method: http.MethodGet, PathRegex: "/r"}
+	var calls, served, notFound int64
+	server.Handle(pDetail, countingHandler{name: "initial", calls: &calls})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				switch resp := serve(server, "/r"); resp.Code {
+				case http.StatusOK:
+					atomic.AddInt64(&served, 1)
+				case http.StatusNotFound:
+					atomic.AddInt64(&notFound, 1)
+				default:
+					t.Errorf("unexpected response %d %s", resp.Code, resp.Body.String())
+				}
+			}
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if j%3 == 0 {
+					ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+					if err := server.Remove(ctx, pDetail); err != nil && err == ctx.Err() {
+						t.Errorf("Remove did not drain the route: %s", err.Error())
+					}
+					cancel()
+					continue
+				}
+				handler := countingHandler{name: fmt.Sprint("replaced ", i, " ", j), calls: &calls}
+				if err := server.Replace(pDetail, handler); err != nil {
+					t.Errorf("unexpected error %s", err.Error())
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if served+notFound != 8*200 || served != atomic.LoadInt64(&calls) {
+		t.Errorf("expected every request to be answered once, got %d served, %d not found, %d handler calls",
+			served, notFound, calls)
+	}
+	if err := server.Replace(pDetail, countingHandler{name: "last", calls: &calls}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Remove(ctx, pDetail); err != nil {
+		t.Errorf("expected the last route to drain, got %s", err.Error())
+	}
+}
@@ -0,0 +1,187 @@
+package charon
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StaticOptions configures the serving of static files
+type StaticOptions struct {
+	// Index the file served for directories, defaults to "index.html"
+	Index string
+
+	// SPAFallback serves the root Index file for the paths without a file extension that do not exist,
+	// so that the client side routes of a single page application are served by the application
+	SPAFallback bool
+
+	// Precompressed serves the ".gz" variant of a file, if present, to the clients accepting gzip
+	Precompressed bool
+
+	// MaxAge sets the max-age of the Cache-Control header, no Cache-Control header is sent if zero
+	MaxAge time.Duration
+}
+
+// staticHandler serves the files of a fs.FS, see NewStaticHandler
+type staticHandler struct {
+	fsys    fs.FS
+	options StaticOptions
+
+	// the ETags of the files served, keyed by name, size and modification time
+	etags sync.Map
+}
+
+// NewStaticHandler returns an http.Handler serving the files of the given fs.FS, like an embed.FS or os.DirFS.
+// The file is resolved from the "filepath" catch-all of the route, or the request path otherwise.
+// Conditional requests are answered using the ETag and Last-Modified of the file, and Range requests
+// are supported. Directory listings are never served
+func NewStaticHandler(fsys fs.FS, staticOptions StaticOptions) http.Handler {
+	if staticOptions.Index == "" {
+		staticOptions.Index = "index.html"
+	}
+	return &staticHandler{fsys: fsys, options: staticOptions}
+}
+
+// ServeHTTP serves the requested file
+func (handler *staticHandler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		resp.Header().Set("Allow", "GET, HEAD")
+		http.Error(resp, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := req.URL.Path
+	if rDetails := RouteDetailsFromContext(req.Context()); rDetails != nil {
+		if filepath, ok := rDetails.PathParams()["filepath"]; ok {
+			name = filepath
+		}
+	}
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+
+	if handler.serveFile(resp, req, name) {
+		return
+	}
+	if handler.options.SPAFallback && path.Ext(name) == "" && handler.serveFile(resp, req, handler.options.Index) {
+		return
+	}
+	http.NotFound(resp, req)
+}
+
+// serveFile serves the file with the given name, returns false if there is no such file
+func (handler *staticHandler) serveFile(resp http.ResponseWriter, req *http.Request, name string) bool {
+	if !fs.ValidPath(name) {
+		return false
+	}
+	info, err := fs.Stat(handler.fsys, name)
+	if err != nil {
+		return false
+	}
+	if info.IsDir() {
+		name = path.Join(name, handler.options.Index)
+		if info, err = fs.Stat(handler.fsys, name); err != nil || info.IsDir() {
+			return false
+		}
+	}
+
+	served, gzipped := name, false
+	if handler.options.Precompressed && acceptsGzip(req) {
+		if gzInfo, err := fs.Stat(handler.fsys, name+".gz"); err == nil && !gzInfo.IsDir() {
+			served, gzipped, info = name+".gz", true, gzInfo
+		}
+	}
+
+	file, err := handler.fsys.Open(served)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		data, err := io.ReadAll(file)
+		if err != nil {
+			return false
+		}
+		content = bytes.NewReader(data)
+	}
+	etag, err := handler.etag(served, info, content)
+	if err != nil {
+		return false
+	}
+
+	header := resp.Header()
+	header.Set("ETag", etag)
+	if handler.options.Precompressed {
+		header.Add("Vary", "Accept-Encoding")
+	}
+	if gzipped {
+		header.Set("Content-Encoding", "gzip")
+	}
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	if handler.options.MaxAge > 0 {
+		header.Set("Cache-Control", fmt.Sprint("public, max-age=", int(handler.options.MaxAge.Seconds())))
+	}
+
+	// the name of the uncompressed file, so that the content type is detected from its extension
+	http.ServeContent(resp, req, path.Base(name), info.ModTime(), content)
+	return true
+}
+
+// etag returns the strong ETag of the file, computed from its content the first time it is served
+func (handler *staticHandler) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	key := fmt.Sprint(name, ":", info.Size(), ":", info.ModTime().UnixNano())
+	if etag, ok := handler.etags.Load(key); ok {
+		return etag.(string), nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+	handler.etags.Store(key, etag)
+	return etag, nil
+}
+
+// acceptsGzip checks if the client accepts gzip encoded responses
+func acceptsGzip(req *http.Request) bool {
+	for _, value := range req.Header.Values("Accept-Encoding") {
+		for _, encoding := range strings.Split(value, ",") {
+			params := strings.Split(encoding, ";")
+			coding := strings.ToLower(strings.TrimSpace(params[0]))
+			if coding != "gzip" && coding != "*" {
+				continue
+			}
+			accepted := true
+			for _, param := range params[1:] {
+				param = strings.TrimSpace(param)
+				if strings.HasPrefix(param, "q=") {
+					q, err := strconv.ParseFloat(param[2:], 64)
+					accepted = err == nil && q > 0
+				}
+			}
+			if accepted {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,295 @@
+package charon
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/charon/errors"
+)
+
+// validateTag the struct tag holding the validation rules of a field
+const validateTag = "validate"
+
+// compiled regexes of the `regex` rules, keyed by expression
+var validateRegexes sync.Map
+
+// Validate validates the struct pointed to by v as per the `validate` tags of its fields.
+// A tag is a comma separated list of rules:
+//
+//	required    the field must not be the zero value
+//	min=n       the minimum value of numbers, or the minimum length of strings, slices and maps
+//	max=n       the maximum value of numbers, or the maximum length of strings, slices and maps
+//	len=n       the exact length of strings, slices and maps
+//	oneof=a b   the field must be one of the space separated values
+//	email       the field must be an email address
+//	regex=expr  the field must match the regular expression, must be the last rule of the tag
+//
+// Fields holding the zero value are only checked against the required rule. Nested structs
+// are validated as well. Returns an errors.InvalidInputError listing each invalid field,
+// named as per their `json` tags
+func Validate(v interface{}) errors.Error {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var fieldErrs []errors.FieldError
+	if err := validateStruct(value, "", &fieldErrs); err != nil {
+		return errors.InternalError{Err: err.Error()}
+	}
+	return newValidationError(fieldErrs)
+}
+
+// newValidationError returns the InvalidInputError for the given field errors, nil if there are none
+func newValidationError(fieldErrs []errors.FieldError) errors.Error {
+	if len(fieldErrs) == 0 {
+		return nil
+	}
+	reasons := make([]string, 0, len(fieldErrs))
+	for _, fieldErr := range fieldErrs {
+		reasons = append(reasons, fieldErr.Field+": "+fieldErr.Reason)
+	}
+	return errors.InvalidInputError{
+		Err:    "Invalid fields, " + strings.Join(reasons, "; "),
+		Mess:   "Invalid input",
+		Fields: fieldErrs,
+	}
+}
+
+// validateStruct validates the fields of the struct, the returned error is for invalid rules
+func validateStruct(value reflect.Value, prefix string, fieldErrs *[]errors.FieldError) error {
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		structField := valueType.Field(i)
+		if structField.PkgPath != "" {
+			continue
+		}
+		name := fieldName(structField)
+		if name == "" {
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+
+		field := value.Field(i)
+		if tag := structField.Tag.Get(validateTag); tag != "" && tag != "-" {
+			reason, err := validateField(field, tag)
+			if err != nil {
+				return fmt.Errorf("charon: invalid validate tag on %s.%s: %s", valueType, structField.Name, err.Error())
+			}
+			if reason != "" {
+				*fieldErrs = append(*fieldErrs, errors.FieldError{Field: name, Reason: reason})
+				continue
+			}
+		}
+		if err := validateNested(field, name, fieldErrs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateNested validates the structs held by the field, directly or in a slice
+func validateNested(field reflect.Value, name string, fieldErrs *[]errors.FieldError) error {
+	for field.Kind() == reflect.Ptr {
+		if field.IsNil() {
+			return nil
+		}
+		field = field.Elem()
+	}
+	switch field.Kind() {
+	case reflect.Struct:
+		return validateStruct(field, name, fieldErrs)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < field.Len(); i++ {
+			if err := validateNested(field.Index(i), fmt.Sprint(name, "[", i, "]"), fieldErrs); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateField checks the field against the rules of the tag, returning the reason it is invalid, if so
+func validateField(field reflect.Value, tag string) (string, error) {
+	rules := splitRules(tag)
+	required := false
+	for _, rule := range rules {
+		if rule == "required" {
+			required = true
+		}
+	}
+	if field.IsZero() {
+		if required {
+			return "is required", nil
+		}
+		return "", nil
+	}
+	for field.Kind() == reflect.Ptr {
+		field = field.Elem()
+	}
+
+	for _, rule := range rules {
+		name, param := rule, ""
+		if idx := strings.Index(rule, "="); idx >= 0 {
+			name, param = rule[:idx], rule[idx+1:]
+		}
+
+		var reason string
+		var err error
+		switch name {
+		case "required":
+		case "min":
+			reason, err = checkBound(field, param, true)
+		case "max":
+			reason, err = checkBound(field, param, false)
+		case "len":
+			reason, err = checkLen(field, param)
+		case "oneof":
+			reason = checkOneOf(field, param)
+		case "email":
+			reason = checkEmail(field)
+		case "regex":
+			reason, err = checkRegex(field, param)
+		default:
+			err = fmt.Errorf("unknown rule %q", name)
+		}
+		if err != nil || reason != "" {
+			return reason, err
+		}
+	}
+	return "", nil
+}
+
+// splitRules splits the tag into its rules, the regex rule takes the rest of the tag
+func splitRules(tag string) []string {
+	var rules []string
+	for tag != "" {
+		if strings.HasPrefix(tag, "regex=") {
+			return append(rules, tag)
+		}
+		rule := tag
+		if idx := strings.Index(tag, ","); idx >= 0 {
+			rule, tag = tag[:idx], tag[idx+1:]
+		} else {
+			tag = ""
+		}
+		if rule = strings.TrimSpace(rule); rule != "" {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// checkBound checks the value, or length, of the field against the min or max bound
+func checkBound(field reflect.Value, param string, isMin bool) (string, error) {
+	bound, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return "", fmt.Errorf("invalid bound %q", param)
+	}
+
+	var actual float64
+	isLength := false
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		actual = float64(field.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		actual = float64(field.Uint())
+	case reflect.Float32, reflect.Float64:
+		actual = field.Float()
+	case reflect.String:
+		actual, isLength = float64(utf8.RuneCountInString(field.String())), true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		actual, isLength = float64(field.Len()), true
+	default:
+		return "", fmt.Errorf("bound on unsupported kind %s", field.Kind())
+	}
+
+	switch {
+	case isMin && actual < bound && isLength:
+		return "must have a length of at least " + param, nil
+	case isMin && actual < bound:
+		return "must be at least " + param, nil
+	case !isMin && actual > bound && isLength:
+		return "must have a length of at most " + param, nil
+	case !isMin && actual > bound:
+		return "must be at most " + param, nil
+	}
+	return "", nil
+}
+
+// checkLen checks the exact length of the field
+func checkLen(field reflect.Value, param string) (string, error) {
+	expected, err := strconv.Atoi(param)
+	if err != nil {
+		return "", fmt.Errorf("invalid length %q", param)
+	}
+
+	var actual int
+	switch field.Kind() {
+	case reflect.String:
+		actual = utf8.RuneCountInString(field.String())
+	case reflect.Slice, reflect.Array, reflect.Map:
+		actual = field.Len()
+	default:
+		return "", fmt.Errorf("len on unsupported kind %s", field.Kind())
+	}
+	if actual != expected {
+		return "must have a length of " + param, nil
+	}
+	return "", nil
+}
+
+// checkOneOf checks that the field is one of the space separated values
+func checkOneOf(field reflect.Value, param string) string {
+	actual := fmt.Sprint(field.Interface())
+	for _, allowed := range strings.Fields(param) {
+		if actual == allowed {
+			return ""
+		}
+	}
+	return "must be one of " + strings.Join(strings.Fields(param), ", ")
+}
+
+// checkEmail checks that the field is a bare email address
+func checkEmail(field reflect.Value) string {
+	if field.Kind() == reflect.String {
+		if address, err := mail.ParseAddress(field.String()); err == nil && address.Address == field.String() {
+			return ""
+		}
+	}
+	return "must be a valid email address"
+}
+
+// checkRegex checks that the field matches the regular expression
+func checkRegex(field reflect.Value, expr string) (string, error) {
+	var regex *regexp.Regexp
+	if cached, ok := validateRegexes.Load(expr); ok {
+		regex = cached.(*regexp.Regexp)
+	} else {
+		compiled, err := regexp.Compile(expr)
+		if err != nil {
+			return "", fmt.Errorf("invalid regex %q", expr)
+		}
+		validateRegexes.Store(expr, compiled)
+		regex = compiled
+	}
+
+	if field.Kind() != reflect.String || !regex.MatchString(field.String()) {
+		return "must match " + expr, nil
+	}
+	return "", nil
+}